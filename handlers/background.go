@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/logging"
+)
+
+const backgroundTaskTimeout = 30 * time.Second
+
+// runInBackground starts fn in its own goroutine and returns at once, so fn
+// runs alongside the response and its duration doesn't add to the response
+// time. fn gets a context that outlives the request but keeps its logger
+// and trace; shutdown waits for it through cfg.Background before closing
+// the database.
+func runInBackground(cfg *config.ApiConfig, r *http.Request, task string, fn func(ctx context.Context) error) {
+	ctx := context.WithoutCancel(r.Context())
+
+	cfg.Background.Add(1)
+	go func() {
+		defer cfg.Background.Done()
+		ctx, cancel := context.WithTimeout(ctx, backgroundTaskTimeout)
+		defer cancel()
+
+		defer func() {
+			if p := recover(); p != nil {
+				logging.FromContext(ctx).Error("background task panicked", "task", task, "error", fmt.Errorf("%v", p))
+			}
+		}()
+
+		if err := fn(ctx); err != nil {
+			logging.FromContext(ctx).Warn("background task failed", "task", task, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/internal/mailer"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+)
+
+type profileResponse struct {
+	FullName      string  `json:"full_name"`
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"email_verified"`
+	Phone         *string `json:"phone"`
+}
+
+func HandlerChangePassword(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if !verifyCurrentPassword(r.Context(), cfg, user, params.CurrentPassword) {
+		return middlewares.InvalidFieldError("current_password", "incorrect", "Current password is incorrect")
+	}
+
+	if err := cfg.PasswordPolicy.Validate(params.NewPassword, user.Username, user.Email); err != nil {
+		return passwordPolicyError("new_password", err)
+	}
+
+	hashedPassword, err := cfg.PasswordHasher.Hash(params.NewPassword)
+	if err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("hash password: %w", err))
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	queriesTx := withTx(tx)
+	now := time.Now().Local()
+
+	if err := queriesTx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		UpdatedAt: now,
+		Password:  hashedPassword,
+		ID:        user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("update password: %w", err))
+	}
+
+	if err := queriesTx.InvalidatePasswordResetsByUserID(r.Context(), database.InvalidatePasswordResetsByUserIDParams{
+		UsedAt: sql.NullTime{Time: now, Valid: true},
+		UserID: user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("invalidate password resets: %w", err))
+	}
+
+	// Every access token issued before now is rejected by the auth
+	// middleware; the session issued below keeps this client signed in.
+	if err := queriesTx.UpdateUserTokensInvalidBefore(r.Context(), database.UpdateUserTokensInvalidBeforeParams{
+		UpdatedAt:           now,
+		TokensInvalidBefore: tokensInvalidBeforeNow(),
+		ID:                  user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("revoke other sessions: %w", err))
+	}
+
+	session, err := issueSession(r.Context(), cfg, queriesTx, user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("issue session: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't change password", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	setSessionCookies(w, session)
+
+	userResp := map[string]string{
+		"message": "Password changed successfully",
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+func HandlerChangeEmail(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		Email           string `json:"email" validate:"required,email"`
+		CurrentPassword string `json:"current_password" validate:"required"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if !verifyCurrentPassword(r.Context(), cfg, user, params.CurrentPassword) {
+		return middlewares.InvalidFieldError("current_password", "incorrect", "Current password is incorrect")
+	}
+
+	if params.Email == user.Email {
+		return middlewares.InvalidFieldError("email", "unchanged", "New email must be different from the current one")
+	}
+
+	exists, err := cfg.DB.CheckUserExistsByEmail(r.Context(), params.Email)
+	if err != nil {
+		return middlewares.InternalError("Couldn't change email", fmt.Errorf("check email: %w", err))
+	}
+	if exists {
+		return middlewares.ConflictError("email_taken", "An account with this email already exists")
+	}
+
+	err = cfg.DB.UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{
+		UpdatedAt: time.Now().Local(),
+		Email:     params.Email,
+		ID:        user.ID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't change email", fmt.Errorf("update email: %w", err))
+	}
+
+	oldEmail := user.Email
+	user.Email = params.Email
+	user.EmailVerifiedAt = sql.NullTime{}
+
+	if claimed, err := claimVerificationEmailSlot(r.Context(), cfg, user.ID); err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't claim verification email slot", "error", err)
+	} else if claimed {
+		if err := sendVerificationEmail(r.Context(), cfg, user); err != nil {
+			logging.FromContext(r.Context()).Warn("couldn't send verification email", "error", err)
+		}
+	}
+
+	if err := cfg.Mailer.Send(r.Context(), mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body:    "The email address on your account was changed to " + params.Email + ". If you didn't do this, reset your password right away.",
+	}); err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't send email change notice", "error", err)
+	}
+
+	userResp := map[string]any{
+		"message": "Email changed, please verify the new address",
+		"profile": toProfileResponse(user),
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+func HandlerUpdateProfile(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		FullName *string `json:"full_name" validate:"notblank,max=100"`
+		Phone    *string `json:"phone" validate:"max=20"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if params.FullName != nil {
+		fullName := strings.TrimSpace(*params.FullName)
+		if fullName != user.FullName {
+			exists, err := cfg.DB.CheckUserExistsByFullname(r.Context(), fullName)
+			if err != nil {
+				return middlewares.InternalError("Couldn't update profile", fmt.Errorf("check full name: %w", err))
+			}
+			if exists {
+				return middlewares.ConflictError("name_taken", "An account with this name already exists")
+			}
+		}
+
+		user.FullName = fullName
+	}
+
+	if params.Phone != nil {
+		phone := strings.TrimSpace(*params.Phone)
+		user.Phone = sql.NullString{String: phone, Valid: phone != ""}
+	}
+
+	err := cfg.DB.UpdateUserInfo(r.Context(), database.UpdateUserInfoParams{
+		UpdatedAt: time.Now().Local(),
+		FullName:  user.FullName,
+		Email:     user.Email,
+		Phone:     user.Phone,
+		ID:        user.ID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't update profile", fmt.Errorf("update profile: %w", err))
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, toProfileResponse(user))
+	return nil
+}
+
+func verifyCurrentPassword(ctx context.Context, cfg *config.ApiConfig, user database.User, password string) bool {
+	match, _, err := cfg.PasswordHasher.Verify(password, user.Password)
+	if err != nil {
+		logging.FromContext(ctx).Warn("couldn't verify password", "error", err)
+		return false
+	}
+	return match
+}
+
+// verifyReauthentication checks the proof a sensitive change asks for: the
+// current password or, for accounts created through OIDC whose password
+// the user never chose, a reauth_token from a fresh provider login.
+func verifyReauthentication(ctx context.Context, cfg *config.ApiConfig, user database.User, currentPassword, reauthToken string) error {
+	switch {
+	case reauthToken != "":
+		claims, err := security.ValidateReauthToken(reauthToken, cfg.JWTKeys, cfg.Token)
+		if err != nil || claims.UserID.String() != user.ID {
+			return middlewares.InvalidFieldError("reauth_token", "invalid", "Reauthentication token is invalid or expired")
+		}
+	case currentPassword == "":
+		return middlewares.InvalidFieldError("current_password", "required", "Current password or reauth_token is required")
+	case !verifyCurrentPassword(ctx, cfg, user, currentPassword):
+		return middlewares.InvalidFieldError("current_password", "incorrect", "Current password is incorrect")
+	}
+	return nil
+}
+
+// passwordPolicyError reports a password rejected by the policy as an error
+// on field, keeping the policy's code.
+func passwordPolicyError(field string, err error) error {
+	var policyErr *security.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return middlewares.InternalError("Couldn't check password", err)
+	}
+	return middlewares.InvalidFieldError(field, policyErr.Code, policyErr.Message)
+}
+
+func toProfileResponse(user database.User) profileResponse {
+	resp := profileResponse{
+		FullName:      user.FullName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
+	if user.Phone.Valid {
+		resp.Phone = &user.Phone.String
+	}
+	return resp
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probeHandler answers 200 for any authenticated request.
+func probeHandler(_ *config.ApiConfig, w http.ResponseWriter, _ *http.Request, _ database.User) error {
+	middlewares.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	return nil
+}
+
+func TestChangePasswordRevokesOlderSessions(t *testing.T) {
+	// East of UTC, a cut-off stored as local wall-clock time lands hours in
+	// the future and rejects the session issued with it.
+	setLocalZone(t, time.FixedZone("UTC+7", 7*60*60))
+
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "old-password-1")
+	oldToken := signAccessToken(t, cfg, user.ID, time.Now().Add(-time.Minute))
+
+	cutoff := &captureArg{}
+	expectAuthenticated(mock, user)
+	mock.ExpectBegin()
+	mock.ExpectExec("UpdateUserPassword").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("InvalidatePasswordResetsByUserID").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UpdateUserTokensInvalidBefore").
+		WithArgs(sqlmock.AnyArg(), cutoff, user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UpdateUserKey").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UpdateUserTK").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(jsonRequest(t, http.MethodPut, "/v1/user/password", map[string]string{
+		"current_password": "old-password-1",
+		"new_password":     "new-password-2",
+	}), oldToken)
+	middlewares.MiddlewareAuth(cfg, HandlerChangePassword)(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	newToken := responseCookie(rec, "access_token")
+	require.NotEmpty(t, newToken)
+	user.TokensInvalidBefore = sql.NullTime{Time: storedTimestamp(t, cutoff.value), Valid: true}
+
+	probe := middlewares.MiddlewareAuth(cfg, probeHandler)
+
+	expectAuthenticated(mock, user)
+	rec = httptest.NewRecorder()
+	probe(rec, withAccessToken(httptest.NewRequest(http.MethodGet, "/v1/user", nil), newToken))
+	assert.Equal(t, http.StatusOK, rec.Code, "new session must be accepted: %s", rec.Body.String())
+
+	expectGetUser(mock, user)
+	rec = httptest.NewRecorder()
+	probe(rec, withAccessToken(httptest.NewRequest(http.MethodGet, "/v1/user", nil), oldToken))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, middlewares.AuthReasonSessionRevoked, decodeProblem(t, rec).Code)
+}
+
+func TestChangePasswordRejections(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          map[string]string
+		expectedField string
+		expectedCode  string
+	}{
+		{"wrong current password", map[string]string{"current_password": "not-my-password", "new_password": "new-password-2"}, "current_password", "incorrect"},
+		{"new password too short", map[string]string{"current_password": "old-password-1", "new_password": "short"}, "new_password", "too_short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			user := newTestUser(t, cfg, "old-password-1")
+			expectAuthenticated(mock, user)
+
+			rec := httptest.NewRecorder()
+			req := withAccessToken(jsonRequest(t, http.MethodPut, "/v1/user/password", tt.body),
+				signAccessToken(t, cfg, user.ID, time.Now()))
+			middlewares.MiddlewareAuth(cfg, HandlerChangePassword)(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			problem := decodeProblem(t, rec)
+			require.Len(t, problem.Errors, 1)
+			assert.Equal(t, tt.expectedField, problem.Errors[0].Field)
+			assert.Equal(t, tt.expectedCode, problem.Errors[0].Code)
+		})
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	user.EmailVerifiedAt = sql.NullTime{Time: user.CreatedAt, Valid: true}
+
+	expectAuthenticated(mock, user)
+	mock.ExpectQuery("CheckUserExistsByEmail").WithArgs("new@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UpdateUserEmail").
+		WithArgs(sqlmock.AnyArg(), "new@example.com", user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("ClaimEmailVerificationSlot").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(jsonRequest(t, http.MethodPut, "/v1/user/email", map[string]string{
+		"email":            "new@example.com",
+		"current_password": "password-1",
+	}), signAccessToken(t, cfg, user.ID, time.Now()))
+	middlewares.MiddlewareAuth(cfg, HandlerChangeEmail)(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Profile profileResponse `json:"profile"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "new@example.com", resp.Profile.Email)
+	assert.False(t, resp.Profile.EmailVerified)
+
+	sent := cfg.Mailer.(*recordingMailer).sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "new@example.com", sent[0].To)
+	assert.Contains(t, sent[0].Body, cfg.FrontendURL+"/verify-email")
+	assert.Equal(t, "test@example.com", sent[1].To)
+	assert.Contains(t, sent[1].Body, "new@example.com")
+}
+
+func TestChangeEmailRejections(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           map[string]string
+		emailTaken     bool
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"wrong current password", map[string]string{"email": "new@example.com", "current_password": "wrong"}, false, http.StatusBadRequest, "validation_failed"},
+		{"unchanged email", map[string]string{"email": "test@example.com", "current_password": "password-1"}, false, http.StatusBadRequest, "validation_failed"},
+		{"email taken", map[string]string{"email": "taken@example.com", "current_password": "password-1"}, true, http.StatusConflict, "email_taken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			user := newTestUser(t, cfg, "password-1")
+			expectAuthenticated(mock, user)
+			if tt.emailTaken {
+				mock.ExpectQuery("CheckUserExistsByEmail").WithArgs(tt.body["email"]).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			}
+
+			rec := httptest.NewRecorder()
+			req := withAccessToken(jsonRequest(t, http.MethodPut, "/v1/user/email", tt.body),
+				signAccessToken(t, cfg, user.ID, time.Now()))
+			middlewares.MiddlewareAuth(cfg, HandlerChangeEmail)(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedCode, decodeProblem(t, rec).Code)
+			assert.Empty(t, cfg.Mailer.(*recordingMailer).sent())
+		})
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+
+	expectAuthenticated(mock, user)
+	mock.ExpectQuery("CheckUserExistsByFullname").WithArgs("New Name").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UpdateUserInfo").
+		WithArgs(sqlmock.AnyArg(), "New Name", user.Email, "+66 123", user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(jsonRequest(t, http.MethodPut, "/v1/user", map[string]string{
+		"full_name": "  New Name ",
+		"phone":     " +66 123 ",
+	}), signAccessToken(t, cfg, user.ID, time.Now()))
+	middlewares.MiddlewareAuth(cfg, HandlerUpdateProfile)(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp profileResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "New Name", resp.FullName)
+	require.NotNil(t, resp.Phone)
+	assert.Equal(t, "+66 123", *resp.Phone)
+}
+
+func TestUpdateProfileRejections(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           map[string]string
+		nameTaken      bool
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"blank full name", map[string]string{"full_name": "   "}, false, http.StatusBadRequest, "validation_failed"},
+		{"phone too long", map[string]string{"phone": "012345678901234567890"}, false, http.StatusBadRequest, "validation_failed"},
+		{"full name taken", map[string]string{"full_name": "Someone Else"}, true, http.StatusConflict, "name_taken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			user := newTestUser(t, cfg, "password-1")
+			expectAuthenticated(mock, user)
+			if tt.nameTaken {
+				mock.ExpectQuery("CheckUserExistsByFullname").WithArgs(tt.body["full_name"]).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			}
+
+			rec := httptest.NewRecorder()
+			req := withAccessToken(jsonRequest(t, http.MethodPut, "/v1/user", tt.body),
+				signAccessToken(t, cfg, user.ID, time.Now()))
+			middlewares.MiddlewareAuth(cfg, HandlerUpdateProfile)(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedCode, decodeProblem(t, rec).Code)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/internal/models"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/go-chi/chi/v5"
+)
+
+type lockoutResponse struct {
+	UserID              string     `json:"user_id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	FailedLoginAttempts int32      `json:"failed_login_attempts"`
+	Locked              bool       `json:"locked"`
+	LockedUntil         *time.Time `json:"locked_until"`
+}
+
+func HandlerGetLockedUsers(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	rows, err := cfg.DB.GetLockedUsers(r.Context(), sql.NullTime{Time: time.Now().UTC(), Valid: true})
+	if err != nil {
+		return middlewares.InternalError("Couldn't get locked users", err)
+	}
+
+	resp := make([]lockoutResponse, 0, len(rows))
+	for _, row := range rows {
+		resp = append(resp, lockoutResponse{
+			UserID:              row.ID,
+			Username:            row.Username,
+			Email:               row.Email,
+			FailedLoginAttempts: row.FailedLoginAttempts,
+			Locked:              true,
+			LockedUntil:         &row.LockedUntil.Time,
+		})
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func HandlerGetUserLockout(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	target, err := getTargetUser(cfg, r)
+	if err != nil {
+		return err
+	}
+
+	resp := lockoutResponse{
+		UserID:              target.ID,
+		Username:            target.Username,
+		Email:               target.Email,
+		FailedLoginAttempts: target.FailedLoginAttempts,
+		Locked:              isSigninLocked(target),
+	}
+	if target.LockedUntil.Valid {
+		resp.LockedUntil = &target.LockedUntil.Time
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func HandlerUnlockUser(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	target, err := getTargetUser(cfg, r)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.DB.ResetFailedLogins(r.Context(), target.ID); err != nil {
+		return middlewares.InternalError("Couldn't unlock user", err)
+	}
+
+	logging.FromContext(r.Context()).Info("user unlocked", "user_id", target.ID, "admin_id", user.ID)
+
+	userResp := map[string]string{
+		"message": "User unlocked successfully",
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+type rolePolicyResponse struct {
+	Role             string     `json:"role"`
+	RequireTwoFactor bool       `json:"require_two_factor"`
+	UpdatedAt        *time.Time `json:"updated_at"`
+}
+
+// HandlerGetRolePolicies lists every role, including those that have never
+// had a policy stored and so fall back to the defaults.
+func HandlerGetRolePolicies(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	policies, err := cfg.DB.GetRolePolicies(r.Context())
+	if err != nil {
+		return middlewares.InternalError("Couldn't get role policies", err)
+	}
+
+	stored := make(map[string]database.RolePolicy, len(policies))
+	for _, policy := range policies {
+		stored[policy.Role] = policy
+	}
+
+	resp := make([]rolePolicyResponse, 0, len(models.Roles))
+	for _, role := range models.Roles {
+		item := rolePolicyResponse{Role: role}
+		if policy, ok := stored[role]; ok {
+			item.RequireTwoFactor = policy.RequireTotp
+			item.UpdatedAt = &policy.UpdatedAt
+		}
+		resp = append(resp, item)
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func HandlerUpdateRolePolicy(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		RequireTwoFactor *bool `json:"require_two_factor" validate:"required"`
+	}
+
+	role := chi.URLParam(r, "role")
+	if !models.IsValidRole(role) {
+		return middlewares.NotFoundError("role_not_found", "Couldn't find role")
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	now := time.Now().Local()
+	err := cfg.DB.UpsertRolePolicy(r.Context(), database.UpsertRolePolicyParams{
+		Role:        role,
+		UpdatedAt:   now,
+		RequireTotp: *params.RequireTwoFactor,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't update role policy", err)
+	}
+
+	logging.FromContext(r.Context()).Info("role policy updated", "role", role, "require_two_factor", *params.RequireTwoFactor, "admin_id", user.ID)
+
+	middlewares.RespondWithJSON(w, http.StatusOK, rolePolicyResponse{
+		Role:             role,
+		RequireTwoFactor: *params.RequireTwoFactor,
+		UpdatedAt:        &now,
+	})
+	return nil
+}
+
+func getTargetUser(cfg *config.ApiConfig, r *http.Request) (database.User, error) {
+	userID := chi.URLParam(r, "id")
+	if userID == "" {
+		return database.User{}, middlewares.ValidationError("missing_parameter", "Missing user id")
+	}
+
+	target, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, middlewares.NotFoundError("user_not_found", "Couldn't find user")
+		}
+		return database.User{}, middlewares.InternalError("Couldn't get user", fmt.Errorf("get user: %w", err))
+	}
+
+	return target, nil
+}
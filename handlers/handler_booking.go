@@ -2,10 +2,8 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
@@ -16,116 +14,97 @@ import (
 	"github.com/google/uuid"
 )
 
-func HandlerCreateBooking(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+func HandlerCreateBooking(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
 	type parameters struct {
-		CheckIn  string `json:"check_in"`
-		CheckOut string `json:"check_out"`
-		RoomID   string `json:"room_id"`
-		Phone    string `json:"phone"`
+		CheckIn  string `json:"check_in" validate:"required,date"`
+		CheckOut string `json:"check_out" validate:"required,date,after=check_in"`
+		RoomID   string `json:"room_id" validate:"required"`
+		Phone    string `json:"phone" validate:"max=20"`
 	}
 
-	defer r.Body.Close()
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	if err := decoder.Decode(&params); err != nil {
-		log.Println("Decode error: ", err)
-		return
-	}
-
-	layout := "2006-01-02"
-	checkInAt, err := time.Parse(layout, params.CheckIn)
-	if err != nil {
-		log.Println("Invalid check_in format error: ", err)
-		return
+	if cfg.RequireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+		return middlewares.ForbiddenError("email_unverified", "Email address must be verified before booking")
 	}
 
-	checkOutAt, err := time.Parse(layout, params.CheckOut)
-	if err != nil {
-		log.Println("Invalid check_out format error: ", err)
-		return
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
 	}
 
-	if !checkInAt.Before(checkOutAt) {
-		log.Println("Check_in must be before check_out")
-		return
-	}
+	// DecodeJSON has already checked both dates and their order.
+	checkInAt, _ := time.Parse(middlewares.DateLayout, params.CheckIn)
+	checkOutAt, _ := time.Parse(middlewares.DateLayout, params.CheckOut)
 
 	exists, err := cfg.DB.CheckRoomAvailability(r.Context(), database.CheckRoomAvailabilityParams{
 		RoomID:   params.RoomID,
 		CheckIn:  checkOutAt,
 		CheckOut: checkInAt,
 	})
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			err = cfg.DB.CreateBooking(r.Context(), database.CreateBookingParams{
-				ID:        uuid.New().String(),
-				CreatedAt: time.Now().Local(),
-				UpdatedAt: time.Now().Local(),
-				CheckIn:   checkInAt,
-				CheckOut:  checkOutAt,
-				UserID:    user.ID,
-				RoomID:    params.RoomID,
-				Phone:     sql.NullString{String: params.Phone, Valid: params.Phone != ""},
-			})
-			if err != nil {
-				log.Println("Couldn't create booking error: ", err)
-				return
-			}
-		} else {
-			log.Println("Internal server error: ", err)
-			return
-		}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return middlewares.InternalError("Couldn't create booking", fmt.Errorf("check room availability: %w", err))
 	}
-
 	if exists != "" {
-		middlewares.RespondWithError(w, http.StatusConflict, "Room is already booked")
-		return
+		cfg.Metrics.BookingConflict()
+		return middlewares.ConflictError("room_unavailable", "Room is already booked")
+	}
+
+	err = cfg.DB.CreateBooking(r.Context(), database.CreateBookingParams{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().Local(),
+		UpdatedAt: time.Now().Local(),
+		CheckIn:   checkInAt,
+		CheckOut:  checkOutAt,
+		UserID:    user.ID,
+		RoomID:    params.RoomID,
+		Phone:     sql.NullString{String: params.Phone, Valid: params.Phone != ""},
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't create booking", fmt.Errorf("create booking: %w", err))
 	}
-	fmt.Println("exists: ", exists)
+	cfg.Metrics.BookingCreated()
 
 	userResp := map[string]any{
 		"message": "Booking created successfully",
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusCreated, userResp)
+	return nil
 }
 
-func HandlerGetBookingsByUserID(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+func HandlerGetBookingsByUserID(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
 	bookings, err := cfg.DB.GetBookingsByUserID(r.Context(), user.ID)
 	if err != nil {
-		log.Println("Couldn't get booking by user id error: ", err)
-		return
+		return middlewares.InternalError("Couldn't get bookings", fmt.Errorf("get bookings by user id: %w", err))
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusOK, bookings)
+	return nil
 }
 
-func HandlerGetBookingsByRoomID(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+func HandlerGetBookingsByRoomID(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
 	roomID := chi.URLParam(r, "room_id")
 	if roomID == "" {
-		log.Println("Missing room id")
+		return middlewares.ValidationError("missing_parameter", "Missing room id")
 	}
 
 	bookings, err := cfg.DB.GetBookingsByRoomID(r.Context(), roomID)
 	if err != nil {
-		log.Println("Couldn't get booking by room id error: ", err)
-		return
+		return middlewares.InternalError("Couldn't get bookings", fmt.Errorf("get bookings by room id: %w", err))
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusOK, bookings)
+	return nil
 }
 
-func HandlerDeleteBooking(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
-	booking_id := chi.URLParam(r, "id")
-	if booking_id == "" {
-		log.Println("Missing booking id")
+func HandlerDeleteBooking(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	bookingID := chi.URLParam(r, "id")
+	if bookingID == "" {
+		return middlewares.ValidationError("missing_parameter", "Missing booking id")
 	}
 
-	err := cfg.DB.DeleteBooking(r.Context(), booking_id)
+	err := cfg.DB.DeleteBooking(r.Context(), bookingID)
 	if err != nil {
-		log.Println("Couldn't delete booking error: ", err)
-		return
+		return middlewares.InternalError("Couldn't delete booking", fmt.Errorf("delete booking: %w", err))
 	}
 
 	userResp := map[string]any{
@@ -133,25 +112,15 @@ func HandlerDeleteBooking(cfg *config.ApiConfig, w http.ResponseWriter, r *http.
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
 }
 
-func HandlerGetAllBookings(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
-	type Booking struct {
-		ID       string    `json:"id"`
-		CheckIn  time.Time `json:"check_in"`
-		CheckOut time.Time `json:"check_out"`
-		RoomName string    `json:"room_name"`
-	}
-
+func HandlerGetAllBookings(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
 	bookings, err := cfg.DB.GetAllBookings(r.Context())
 	if err != nil {
-		if err == sql.ErrNoRows {
-			middlewares.RespondWithJSON(w, http.StatusOK, Booking{})
-			return
-		}
-		log.Println("Couldn't get all bookings error: ", err)
-		return
+		return middlewares.InternalError("Couldn't get bookings", fmt.Errorf("get all bookings: %w", err))
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusOK, bookings)
+	return nil
 }
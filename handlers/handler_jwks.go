@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/middlewares"
+)
+
+func HandlerJWKS(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	middlewares.RespondWithJSON(w, http.StatusOK, cfg.JWTKeys.JWKS())
+	return nil
+}
@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/internal/metrics"
+	"github.com/STaninnat/booking-backend/internal/oidc"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+	reauthTokenTTL  = 5 * time.Minute
+)
+
+var (
+	errOIDCEmailUnverified = errors.New("identity provider did not return a verified email")
+	errOIDCEmailInUse      = errors.New("email belongs to an account that can't be linked automatically")
+
+	usernameUnsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// HandlerOIDCLogin starts the authorization code flow: it remembers the
+// state, nonce and PKCE verifier in a signed cookie and redirects the
+// browser to the provider.
+func HandlerOIDCLogin(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	provider, ok := cfg.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		return middlewares.NotFoundError("provider_not_found", "Couldn't find identity provider")
+	}
+
+	startOIDCFlow(w, r, cfg, provider, "")
+	return nil
+}
+
+// HandlerOIDCReauth starts the same flow for a signed-in user who has to
+// confirm it's them, forcing a fresh login at the provider. The callback
+// redirects to FRONTEND_URL/account/reauth with a reauth_token in the URL
+// fragment instead of signing in.
+func HandlerOIDCReauth(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	provider, ok := cfg.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		return middlewares.NotFoundError("provider_not_found", "Couldn't find identity provider")
+	}
+
+	startOIDCFlow(w, r, cfg, provider, user.ID)
+	return nil
+}
+
+func startOIDCFlow(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, provider *oidc.Provider, reauthUserID string) {
+	var values [3]string
+	for i := range values {
+		value, err := oidc.GenerateCodeVerifier()
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("couldn't generate oidc state", "error", err)
+			redirectOIDCError(w, r, cfg, "server_error")
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	var opts []oidc.AuthCodeOption
+	if reauthUserID != "" {
+		opts = append(opts, oidc.WithFreshLogin())
+	}
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, oidc.CodeChallengeS256(verifier), opts...)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't build authorization url", "error", err)
+		redirectOIDCError(w, r, cfg, "provider_unavailable")
+		return
+	}
+
+	expiresAt := time.Now().Add(oidcStateTTL)
+	stateToken, err := security.GenerateOIDCStateToken(security.OIDCStateClaims{
+		Provider:     provider.Name(),
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ReauthUserID: reauthUserID,
+	}, cfg.JWTKeys, cfg.Token, expiresAt)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't sign oidc state", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return
+	}
+
+	// Lax, not Strict: the callback arrives as a top-level navigation
+	// from the provider's site and must still carry this cookie.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    stateToken,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// HandlerOIDCCallback finishes the flow, links or provisions the local user
+// and signs them in with the same cookies as HandlerSignin.
+func HandlerOIDCCallback(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	provider, ok := cfg.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		return middlewares.NotFoundError("provider_not_found", "Couldn't find identity provider")
+	}
+
+	stateCookie, err := r.Cookie(oidcStateCookie)
+	clearOIDCStateCookie(w)
+	if err != nil {
+		redirectOIDCError(w, r, cfg, "invalid_state")
+		return nil
+	}
+
+	stateClaims, err := security.ValidateOIDCStateToken(stateCookie.Value, cfg.JWTKeys, cfg.Token)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("invalid oidc state", "error", err)
+		redirectOIDCError(w, r, cfg, "invalid_state")
+		return nil
+	}
+	state := r.URL.Query().Get("state")
+	if stateClaims.Provider != provider.Name() || subtle.ConstantTimeCompare([]byte(state), []byte(stateClaims.State)) != 1 {
+		redirectOIDCError(w, r, cfg, "invalid_state")
+		return nil
+	}
+
+	if providerErr := r.URL.Query().Get("error"); providerErr != "" {
+		logging.FromContext(r.Context()).Warn("oidc provider returned error", "provider", provider.Name(), "error", providerErr)
+		redirectOIDCError(w, r, cfg, "access_denied")
+		return nil
+	}
+
+	rawIDToken, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), stateClaims.CodeVerifier)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't exchange authorization code", "error", err)
+		redirectOIDCError(w, r, cfg, "exchange_failed")
+		return nil
+	}
+
+	idClaims, err := provider.VerifyIDToken(r.Context(), rawIDToken, stateClaims.Nonce)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't verify id token", "error", err)
+		redirectOIDCError(w, r, cfg, "invalid_id_token")
+		return nil
+	}
+
+	if stateClaims.ReauthUserID != "" {
+		finishOIDCReauth(w, r, cfg, provider.Name(), stateClaims, idClaims)
+		return nil
+	}
+
+	user, err := findOrProvisionOIDCUser(r.Context(), cfg, provider.Name(), idClaims)
+	if err != nil {
+		switch {
+		case errors.Is(err, errOIDCEmailUnverified):
+			redirectOIDCError(w, r, cfg, "email_unverified")
+		case errors.Is(err, errOIDCEmailInUse):
+			redirectOIDCError(w, r, cfg, "account_exists")
+		default:
+			logging.FromContext(r.Context()).Warn("couldn't link oidc identity", "error", err)
+			redirectOIDCError(w, r, cfg, "server_error")
+		}
+		return nil
+	}
+
+	if isSigninLocked(user) {
+		redirectOIDCError(w, r, cfg, "account_locked")
+		return nil
+	}
+
+	// The provider stands in for the password, not for the second factor.
+	if user.TotpEnabledAt.Valid {
+		redirectOIDCTwoFactor(w, r, cfg, user.ID)
+		return nil
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("failed to start transaction", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return nil
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	session, err := issueSession(r.Context(), cfg, withTx(tx), user.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't issue session", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		logging.FromContext(r.Context()).Error("failed to commit transaction", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return nil
+	}
+
+	setSessionCookies(w, session)
+	cfg.Metrics.Signin(metrics.SigninOIDC)
+
+	http.Redirect(w, r, cfg.FrontendURL+"/", http.StatusFound)
+	return nil
+}
+
+// redirectOIDCTwoFactor sends a user with 2FA on to the frontend's second
+// step with an mfa_token, as HandlerSignin does after the password.
+func redirectOIDCTwoFactor(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, userID string) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("error parsing user ID", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return
+	}
+	mfaToken, err := security.GenerateTwoFactorToken(id, cfg.JWTKeys, cfg.Token, time.Now().Add(twoFactorTokenTTL))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't generate two-factor token", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return
+	}
+	http.Redirect(w, r, frontendRedirect(cfg, "/signin/2fa", mfaToken), http.StatusFound)
+}
+
+// frontendRedirect hands a short-lived token to the frontend in the URL
+// fragment, which browsers neither send to servers nor put in Referer
+// headers, so it stays out of access logs and third-party requests.
+func frontendRedirect(cfg *config.ApiConfig, path, token string) string {
+	return cfg.FrontendURL + path + "#token=" + url.QueryEscape(token)
+}
+
+// finishOIDCReauth hands out a reauth token when the provider confirmed a
+// fresh login with an identity already linked to the user who started the
+// flow. Nothing is provisioned or linked here.
+func finishOIDCReauth(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, provider string, state *security.OIDCStateClaims, claims *oidc.IDTokenClaims) {
+	// A provider that ignored max_age and reused its session didn't make
+	// the user prove anything just now.
+	if claims.AuthTime == nil || state.IssuedAt == nil || claims.AuthTime.Before(state.IssuedAt.Add(-cfg.Token.Leeway)) {
+		redirectOIDCError(w, r, cfg, "reauth_not_fresh")
+		return
+	}
+
+	identity, err := cfg.DB.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logging.FromContext(r.Context()).Warn("couldn't get oidc identity", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return
+	}
+	if err != nil || identity.UserID != state.ReauthUserID {
+		redirectOIDCError(w, r, cfg, "reauth_mismatch")
+		return
+	}
+
+	userID, err := uuid.Parse(identity.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("error parsing user ID", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return
+	}
+	token, err := security.GenerateReauthToken(userID, cfg.JWTKeys, cfg.Token, time.Now().Add(reauthTokenTTL))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't generate reauth token", "error", err)
+		redirectOIDCError(w, r, cfg, "server_error")
+		return
+	}
+
+	http.Redirect(w, r, frontendRedirect(cfg, "/account/reauth", token), http.StatusFound)
+}
+
+// findOrProvisionOIDCUser resolves the local user for an external identity.
+// An unknown identity is linked to the account with the same email only if
+// both sides have verified that email; otherwise a new guest is created.
+func findOrProvisionOIDCUser(ctx context.Context, cfg *config.ApiConfig, provider string, claims *oidc.IDTokenClaims) (database.User, error) {
+	identity, err := cfg.DB.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		return cfg.DB.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified || !security.IsValidateEmailFormat(claims.Email) {
+		return database.User{}, errOIDCEmailUnverified
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(ctx).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	queriesTx := withTx(tx)
+
+	user, err := queriesTx.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		if !user.EmailVerifiedAt.Valid {
+			return database.User{}, errOIDCEmailInUse
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = provisionOIDCUser(ctx, cfg, queriesTx, claims)
+		if err != nil {
+			return database.User{}, err
+		}
+	default:
+		return database.User{}, err
+	}
+
+	if err := queriesTx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().Local(),
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Email:     sql.NullString{String: claims.Email, Valid: true},
+		UserID:    user.ID,
+	}); err != nil {
+		return database.User{}, fmt.Errorf("couldn't link identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// provisionOIDCUser creates a guest for a first-time OIDC login. The password
+// is random and unknown to anyone; the user can set one through the password
+// reset flow.
+func provisionOIDCUser(ctx context.Context, cfg *config.ApiConfig, queries *database.Queries, claims *oidc.IDTokenClaims) (database.User, error) {
+	username, err := uniqueUsername(ctx, queries, claims.Email)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	randomPassword, err := security.GenerateRandomSHA256HASH()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashedPassword, err := cfg.PasswordHasher.Hash(randomPassword)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	_, hashedApiKey, err := security.GenerateAndHashAPIKey()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	fullName := strings.TrimSpace(claims.Name)
+	if fullName == "" {
+		fullName = username
+	}
+
+	now := time.Now().Local()
+	userID := uuid.New().String()
+
+	if err := queries.CreateUser(ctx, database.CreateUserParams{
+		ID:              userID,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		FullName:        fullName,
+		Email:           claims.Email,
+		Username:        username,
+		Password:        hashedPassword,
+		ApiKey:          hashedApiKey,
+		ApiKeyExpiresAt: now.Add(cfg.Token.RefreshTokenTTL),
+	}); err != nil {
+		return database.User{}, fmt.Errorf("couldn't create user: %w", err)
+	}
+
+	if err := queries.MarkUserEmailVerified(ctx, database.MarkUserEmailVerifiedParams{
+		UpdatedAt:       now,
+		EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+		ID:              userID,
+		Email:           claims.Email,
+	}); err != nil {
+		return database.User{}, fmt.Errorf("couldn't mark email verified: %w", err)
+	}
+
+	// issueSession only updates an existing refresh token row, so create an
+	// already expired one for it to replace.
+	expiredAt := now.AddDate(-1, 0, 0)
+	if err := queries.CreateUserRfKey(ctx, database.CreateUserRfKeyParams{
+		ID:                    uuid.New().String(),
+		CreatedAt:             now,
+		UpdatedAt:             now,
+		AccessTokenExpiresAt:  expiredAt,
+		RefreshToken:          "expired-" + uuid.New().String()[:28],
+		RefreshTokenExpiresAt: expiredAt,
+		UserID:                userID,
+	}); err != nil {
+		return database.User{}, fmt.Errorf("couldn't create refresh token: %w", err)
+	}
+
+	return queries.GetUserByID(ctx, userID)
+}
+
+// uniqueUsername derives a valid username from the email's local part with a
+// random suffix, retrying on the unlikely collision.
+func uniqueUsername(ctx context.Context, queries *database.Queries, email string) (string, error) {
+	base := usernameUnsafeChars.ReplaceAllString(strings.ToLower(strings.SplitN(email, "@", 2)[0]), "")
+	if len(base) > 20 {
+		base = base[:20]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	for range 5 {
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		username := base + "." + hex.EncodeToString(suffix)
+
+		exists, err := queries.CheckUserExistsByUsername(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+	}
+
+	return "", errors.New("couldn't find a free username")
+}
+
+func clearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectOIDCError sends the browser back to the frontend sign-in page;
+// the callback is a navigation, so a JSON error body would be a dead end.
+func redirectOIDCError(w http.ResponseWriter, r *http.Request, cfg *config.ApiConfig, reason string) {
+	http.Redirect(w, r, cfg.FrontendURL+"/signin?error="+url.QueryEscape(reason), http.StatusFound)
+}
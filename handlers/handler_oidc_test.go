@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectOIDCTwoFactorKeepsTokenOutOfQuery(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+	userID := uuid.New()
+
+	rec := httptest.NewRecorder()
+	redirectOIDCTwoFactor(rec, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/test/callback", nil), cfg, userID.String())
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.FrontendURL+"/signin/2fa", location.Scheme+"://"+location.Host+location.Path)
+	assert.Empty(t, location.RawQuery, "the mfa_token must not reach server logs or Referer headers")
+
+	fragment, err := url.ParseQuery(location.Fragment)
+	require.NoError(t, err)
+	claims, err := security.ValidateTwoFactorToken(fragment.Get("token"), cfg.JWTKeys, cfg.Token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/internal/mailer"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+func HandlerForgotPassword(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	// Known and unknown addresses get the same answer, after the same
+	// work, so the endpoint can't be used to discover accounts. Creating
+	// and mailing the token happens after the response.
+	resp := map[string]string{
+		"message": "If an account exists for this email, a password reset link has been sent",
+	}
+
+	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return middlewares.InternalError("Couldn't process request", fmt.Errorf("get user: %w", err))
+	}
+	if err == nil {
+		runInBackground(cfg, r, "password_reset_email", func(ctx context.Context) error {
+			return sendPasswordReset(ctx, cfg, user)
+		})
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusAccepted, resp)
+	return nil
+}
+
+func sendPasswordReset(ctx context.Context, cfg *config.ApiConfig, user database.User) error {
+	token, err := security.GenerateRandomSHA256HASH()
+	if err != nil {
+		return fmt.Errorf("generate reset token: %w", err)
+	}
+
+	err = cfg.DB.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().Local(),
+		TokenHash: security.HashToken(token),
+		ExpiresAt: time.Now().Local().Add(passwordResetTTL),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("create password reset: %w", err)
+	}
+
+	err = cfg.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the link below to choose a new password. It expires in %d minutes and can only be used once.\n\n%s",
+			int(passwordResetTTL.Minutes()), frontendLink(cfg, "/reset-password", token)),
+	})
+	if err != nil {
+		return fmt.Errorf("send password reset email: %w", err)
+	}
+
+	return nil
+}
+
+func HandlerResetPassword(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	reset, err := cfg.DB.GetPasswordResetByTokenHash(r.Context(), database.GetPasswordResetByTokenHashParams{
+		TokenHash: security.HashToken(params.Token),
+		ExpiresAt: time.Now().Local(),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return middlewares.ValidationError("invalid_reset_token", "Invalid or expired reset token")
+		}
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("get password reset: %w", err))
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), reset.UserID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("get user: %w", err))
+	}
+
+	if err := cfg.PasswordPolicy.Validate(params.Password, user.Username, user.Email); err != nil {
+		return passwordPolicyError("password", err)
+	}
+
+	hashedPassword, err := cfg.PasswordHasher.Hash(params.Password)
+	if err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("hash password: %w", err))
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	queriesTx := withTx(tx)
+	usedAt := sql.NullTime{Time: time.Now().Local(), Valid: true}
+
+	// Claiming the token inside the transaction makes it single-use even
+	// when two requests race with the same link.
+	claimed, err := queriesTx.MarkPasswordResetUsed(r.Context(), database.MarkPasswordResetUsedParams{
+		UsedAt: usedAt,
+		ID:     reset.ID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("claim password reset: %w", err))
+	}
+	if claimed == 0 {
+		return middlewares.ValidationError("invalid_reset_token", "Invalid or expired reset token")
+	}
+
+	if err := queriesTx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		UpdatedAt: time.Now().Local(),
+		Password:  hashedPassword,
+		ID:        reset.UserID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("update password: %w", err))
+	}
+
+	if err := queriesTx.InvalidatePasswordResetsByUserID(r.Context(), database.InvalidatePasswordResetsByUserIDParams{
+		UsedAt: usedAt,
+		UserID: reset.UserID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("invalidate password resets: %w", err))
+	}
+
+	if err := revokeUserSessions(r.Context(), queriesTx, reset.UserID); err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("revoke sessions: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't reset password", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	userResp := map[string]string{
+		"message": "Password reset successfully",
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+// frontendLink builds the link mailed to users. Without FRONTEND_URL the
+// path and token are still included so the flow can be completed by hand.
+func frontendLink(cfg *config.ApiConfig, path, token string) string {
+	return cfg.FrontendURL + path + "?token=" + url.QueryEscape(token)
+}
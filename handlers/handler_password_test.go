@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/mailer"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingMailer holds every Send until release is closed.
+type blockingMailer struct {
+	recordingMailer
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return m.recordingMailer.Send(ctx, msg)
+}
+
+func forgotPassword(t *testing.T, cfg *config.ApiConfig, email string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	middlewares.Handle(cfg, HandlerForgotPassword)(rec, jsonRequest(t, http.MethodPost, "/v1/user/password/forgot", map[string]string{"email": email}))
+	return rec
+}
+
+func TestForgotPasswordRespondsBeforeSending(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	mail := &blockingMailer{release: make(chan struct{})}
+	cfg.Mailer = mail
+	user := newTestUser(t, cfg, "password-1")
+
+	mock.ExpectQuery("GetUserByEmail").WithArgs("nobody@example.com").WillReturnError(sql.ErrNoRows)
+	unknown := forgotPassword(t, cfg, "nobody@example.com")
+
+	mock.ExpectQuery("GetUserByEmail").WithArgs(user.Email).WillReturnRows(userRows(user))
+	mock.ExpectExec("CreatePasswordReset").WillReturnResult(sqlmock.NewResult(0, 1))
+	known := forgotPassword(t, cfg, user.Email)
+
+	// The known address has answered while its mail is still blocked.
+	assert.Equal(t, http.StatusAccepted, known.Code)
+	assert.Equal(t, unknown.Code, known.Code)
+	assert.Equal(t, unknown.Body.String(), known.Body.String())
+	assert.Empty(t, mail.sent())
+
+	close(mail.release)
+	cfg.Background.Wait()
+
+	sent := mail.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, user.Email, sent[0].To)
+	assert.Contains(t, sent[0].Body, cfg.FrontendURL+"/reset-password?token=")
+}
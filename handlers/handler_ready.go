@@ -1,15 +1,35 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/STaninnat/booking-backend/internal/config"
 	"github.com/STaninnat/booking-backend/middlewares"
 )
 
-func HandlerReadiness(w http.ResponseWriter, r *http.Request) {
+// HandlerLiveness only reports that the process is serving requests. It
+// checks no dependencies, so a database outage doesn't get the pod restarted.
+func HandlerLiveness(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
 	middlewares.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	return nil
 }
 
-func HandlerError(w http.ResponseWriter, r *http.Request) {
-	middlewares.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+// HandlerReadiness reports each dependency's status and answers 503 when
+// any of them fails or the server is shutting down.
+func HandlerReadiness(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	report, ok := cfg.Readiness.Check(r.Context())
+
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	middlewares.RespondWithJSON(w, status, report)
+	return nil
+}
+
+func HandlerError(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	return middlewares.InternalError("Internal server error", errors.New("error endpoint called"))
 }
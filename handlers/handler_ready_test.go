@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerReadiness(t *testing.T) {
+	failing := errors.New("connection refused")
+	var dbErr error
+	cfg := &config.ApiConfig{
+		Readiness: health.NewReadiness(time.Second, health.Check{Name: "database", Run: func(context.Context) error {
+			return dbErr
+		}}),
+	}
+
+	tests := []struct {
+		name       string
+		dbErr      error
+		wantStatus int
+		wantReport string
+	}{
+		{"ready", nil, http.StatusOK, health.StatusOK},
+		{"database down", failing, http.StatusServiceUnavailable, health.StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbErr = tt.dbErr
+			rec := httptest.NewRecorder()
+			require.NoError(t, HandlerReadiness(cfg, rec, httptest.NewRequest(http.MethodGet, "/readyz", nil)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			var report health.Report
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tt.wantReport, report.Status)
+			assert.Equal(t, tt.wantReport, report.Checks["database"].Status)
+		})
+	}
+}
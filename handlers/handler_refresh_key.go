@@ -1,7 +1,9 @@
 package handlers
 
 import (
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -12,92 +14,87 @@ import (
 	"github.com/google/uuid"
 )
 
-func HandlerRefreshKey(cfg *config.ApiConfig) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("refresh_token")
-		if err != nil {
-			log.Println("Couldn't find token error:", err)
-			return
-		}
-		refreshToken := cookie.Value
-
-		user, err := cfg.DB.GetUserByRfKey(r.Context(), refreshToken)
-		if err != nil {
-			log.Println("Couldn't get user error:", err)
-			return
-		}
+func HandlerRefreshKey(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie("refresh_token")
+	if err != nil {
+		return middlewares.UnauthorizedError("refresh_token_missing", "Missing refresh token")
+	}
+	refreshToken := cookie.Value
 
-		_, newHashedApiKey, err := security.GenerateAndHashAPIKey()
-		if err != nil {
-			log.Println("Couldn't generate new key error:", err)
-			return
+	user, err := cfg.DB.GetUserByRfKey(r.Context(), refreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return middlewares.UnauthorizedError("refresh_token_invalid", "Invalid refresh token")
 		}
+		return middlewares.InternalError("Couldn't refresh token", fmt.Errorf("get user by refresh token: %w", err))
+	}
 
-		userID, err := uuid.Parse(user.UserID)
-		if err != nil {
-			log.Printf("Error parsing user ID: %v", err)
-			return
-		}
+	_, newHashedApiKey, err := security.GenerateAndHashAPIKey()
+	if err != nil {
+		return middlewares.InternalError("Couldn't refresh token", fmt.Errorf("generate new key: %w", err))
+	}
 
-		newApiKeyExpiresAt := time.Now().Local().AddDate(0, 3, 0)
-		newAccessTokenExpiresAt := time.Now().Local().Add(1 * time.Hour)
+	userID, err := uuid.Parse(user.UserID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't refresh token", fmt.Errorf("parse user id: %w", err))
+	}
 
-		newAccessToken, err := security.GenerateJWTToken(userID, cfg.JWTSecret, newAccessTokenExpiresAt)
-		if err != nil {
-			log.Println("Couldn't generate new token error:", err)
-			return
-		}
+	newApiKeyExpiresAt := time.Now().Local().AddDate(0, 3, 0)
+	newAccessTokenExpiresAt := time.Now().Local().Add(cfg.Token.AccessTokenTTL)
 
-		err = cfg.DB.UpdateUserKey(r.Context(), database.UpdateUserKeyParams{
-			UpdatedAt:       time.Now().Local(),
-			ApiKey:          newHashedApiKey,
-			ApiKeyExpiresAt: newApiKeyExpiresAt,
-			ID:              user.UserID,
-		})
-		if err != nil {
-			log.Println("Failed to update apikey error:", err)
-			return
-		}
+	newAccessToken, err := security.GenerateJWTToken(userID, cfg.JWTKeys, cfg.Token, newAccessTokenExpiresAt)
+	if err != nil {
+		return middlewares.InternalError("Couldn't refresh token", fmt.Errorf("generate new token: %w", err))
+	}
 
-		newRefreshTokenExpiresAt := time.Now().Local().Add(30 * 24 * time.Hour)
-		err = cfg.DB.UpdateUserTK(r.Context(), database.UpdateUserTKParams{
-			UpdatedAt:             time.Now().Local(),
-			AccessTokenExpiresAt:  newAccessTokenExpiresAt,
-			RefreshToken:          refreshToken,
-			RefreshTokenExpiresAt: newRefreshTokenExpiresAt,
-			UserID:                user.UserID,
-		})
-		if err != nil {
-			log.Println("Failed to update refresh token error:", err)
-			return
-		}
+	err = cfg.DB.UpdateUserKey(r.Context(), database.UpdateUserKeyParams{
+		UpdatedAt:       time.Now().Local(),
+		ApiKey:          newHashedApiKey,
+		ApiKeyExpiresAt: newApiKeyExpiresAt,
+		ID:              user.UserID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't refresh token", fmt.Errorf("update apikey: %w", err))
+	}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token",
-			Value:    newAccessToken,
-			Expires:  newAccessTokenExpiresAt,
-			HttpOnly: true,
-			Path:     "/",
-			Secure:   true,
-			// SameSite: http.SameSiteStrictMode,
-			SameSite: http.SameSiteLaxMode,
-		})
+	newRefreshTokenExpiresAt := time.Now().Local().Add(cfg.Token.RefreshTokenTTL)
+	err = cfg.DB.UpdateUserTK(r.Context(), database.UpdateUserTKParams{
+		UpdatedAt:             time.Now().Local(),
+		AccessTokenExpiresAt:  newAccessTokenExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: newRefreshTokenExpiresAt,
+		UserID:                user.UserID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't refresh token", fmt.Errorf("update refresh token: %w", err))
+	}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshToken,
-			Expires:  newRefreshTokenExpiresAt,
-			HttpOnly: true,
-			Path:     "/",
-			Secure:   true,
-			// SameSite: http.SameSiteStrictMode,
-			SameSite: http.SameSiteLaxMode,
-		})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token",
+		Value:    newAccessToken,
+		Expires:  newAccessTokenExpiresAt,
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   true,
+		// SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
 
-		userResp := map[string]string{
-			"message": "Token refreshed successfully",
-		}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Expires:  newRefreshTokenExpiresAt,
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   true,
+		// SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
 
-		middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	userResp := map[string]string{
+		"message": "Token refreshed successfully",
 	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
 }
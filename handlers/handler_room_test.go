@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/models"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRoomAcceptsFreeRooms(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+
+	now := time.Now()
+	mock.ExpectQuery("CreateRoom").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "Staff room", sqlmock.AnyArg(), "0.00", int32(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "room_name", "description", "price", "max_guests"}).
+			AddRow("room-1", now, now, "Staff room", nil, "0.00", 2))
+
+	rec := httptest.NewRecorder()
+	middlewares.Handle(cfg, HandlerCreateRoom)(rec, jsonRequest(t, http.MethodPost, "/v1/rooms", map[string]any{
+		"room_name":  "Staff room",
+		"price":      0,
+		"max_guests": 2,
+	}))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var room models.Room
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&room))
+	assert.Equal(t, "room-1", room.ID)
+	assert.Zero(t, room.Price)
+}
+
+func TestCreateRoomRejectsBadPrices(t *testing.T) {
+	tests := []struct {
+		name  string
+		price any
+		want  middlewares.FieldError
+	}{
+		{"missing", nil, middlewares.FieldError{Field: "price", Code: "required", Detail: "price is required"}},
+		{"negative", -1, middlewares.FieldError{Field: "price", Code: "too_small", Detail: "price must be at least 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+
+			body := map[string]any{"room_name": "Suite", "max_guests": 2}
+			if tt.price != nil {
+				body["price"] = tt.price
+			}
+			rec := httptest.NewRecorder()
+			middlewares.Handle(cfg, HandlerCreateRoom)(rec, jsonRequest(t, http.MethodPost, "/v1/rooms", body))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, []middlewares.FieldError{tt.want}, decodeProblem(t, rec).Errors)
+		})
+	}
+}
@@ -1,171 +1,172 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/STaninnat/booking-backend/internal/config"
 	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/internal/metrics"
 	"github.com/STaninnat/booking-backend/middlewares"
-	"github.com/STaninnat/booking-backend/security"
-	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HandlerSignin(cfg *config.ApiConfig) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		type parameters struct {
-			UserName string `json:"username"`
-			Password string `json:"password"`
-		}
+const (
+	maxFailedSignins    = 5
+	accountLockDuration = 15 * time.Minute
+)
+
+func HandlerSignin(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		UserName string `json:"username" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
 
-		defer r.Body.Close()
-		params := parameters{}
-		decoder := json.NewDecoder(r.Body)
-		if err := decoder.Decode(&params); err != nil {
-			log.Println("Decode error: ", err)
-			return
+	ip := middlewares.ClientIP(r)
+	if wait := cfg.LoginThrottle.Check(ip); wait > 0 {
+		return signinLockedError(wait)
+	}
+
+	user, err := cfg.DB.GetUserByUsername(r.Context(), params.UserName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Spend the same hashing time as a real check so response
+			// timing doesn't reveal which usernames exist.
+			cfg.PasswordHasher.VerifyDummy(params.Password)
+			cfg.LoginThrottle.Failure(ip)
+			cfg.Metrics.SigninFailed(metrics.SigninPassword)
+			return middlewares.UnauthorizedError("invalid_credentials", "Invalid credentials")
 		}
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("get user: %w", err))
+	}
 
-		user, err := cfg.DB.GetUserByUsername(r.Context(), params.UserName)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				middlewares.RespondWithError(w, http.StatusBadRequest, "Username not found")
-			} else {
-				log.Println("Retrieving user error: ", err)
+	// The password is checked even while the account is locked, and a wrong
+	// one gets the same 401 as an unknown username, so the lock only shows
+	// to someone who already knows the password.
+	locked := isSigninLocked(user)
+	match, needsRehash, err := cfg.PasswordHasher.Verify(params.Password, user.Password)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't verify password", "error", err)
+	}
+	if !match {
+		cfg.LoginThrottle.Failure(ip)
+		cfg.Metrics.SigninFailed(metrics.SigninPassword)
+		if !locked {
+			if err := recordFailedSignin(r.Context(), cfg, user.ID); err != nil {
+				logging.FromContext(r.Context()).Warn("couldn't record failed sign-in", "error", err)
 			}
-			return
 		}
+		return middlewares.UnauthorizedError("invalid_credentials", "Invalid credentials")
+	}
 
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(params.Password))
-		if err != nil {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "Incorrect password")
-			return
-		}
+	if locked {
+		return signinLockedError(time.Until(user.LockedUntil.Time))
+	}
 
-		jwtExpiresAt := time.Now().Local().Add(1 * time.Hour)
+	if needsRehash {
+		upgradePasswordHash(r.Context(), cfg, user.ID, params.Password)
+	}
 
-		userID, err := uuid.Parse(user.ID)
-		if err != nil {
-			log.Printf("Error parsing user id: %v\n", err)
-			return
-		}
+	// With 2FA enabled a correct password only earns the mfa_token; the
+	// failed-attempt counter is reset once the second factor passes.
+	if user.TotpEnabledAt.Valid {
+		return respondTwoFactorRequired(w, cfg, user.ID)
+	}
 
-		tokenString, err := security.GenerateJWTToken(userID, cfg.JWTSecret, jwtExpiresAt)
-		if err != nil {
-			log.Println("Couldn't generate access token error: ", err)
-			return
+	if user.FailedLoginAttempts > 0 || user.LockedUntil.Valid {
+		if err := cfg.DB.ResetFailedLogins(r.Context(), user.ID); err != nil {
+			logging.FromContext(r.Context()).Warn("couldn't reset failed sign-ins", "error", err)
 		}
+	}
+
+	enrollmentRequired, err := middlewares.RoleRequiresTwoFactor(r.Context(), cfg, user.Role)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't get role policy", "error", err)
+	}
 
-		tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
-		if err != nil {
-			log.Println("Failed to start transaction error: ", err)
-			return
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
 		}
+	}()
 
-		defer func() {
-			if p := recover(); p != nil {
-				if err := tx.Rollback(); err != nil {
-					log.Printf("Failed to rollback transaction: %v\n", err)
-				}
-				panic(p)
-			} else if err != nil {
-				if err := tx.Rollback(); err != nil {
-					log.Printf("Failed to rollback transaction: %v\n", err)
-				}
-			} else {
-				err = tx.Commit()
-				if err != nil {
-					log.Printf("Failed to commit transaction: %v\n", err)
-					return
-				}
-			}
-		}()
+	session, err := issueSession(r.Context(), cfg, withTx(tx), user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("issue session: %w", err))
+	}
 
-		queriesTx := database.New(tx)
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("commit transaction: %w", err))
+	}
 
-		_, hashedApiKey, err := security.GenerateAndHashAPIKey()
-		if err != nil {
-			log.Println("Couldn't generate apikey error: ", err)
-			return
-		}
+	setSessionCookies(w, session)
+	cfg.Metrics.Signin(metrics.SigninPassword)
 
-		keyExpiresAt := time.Now().Local().Add(30 * 24 * time.Hour)
-
-		err = queriesTx.UpdateUserKey(r.Context(), database.UpdateUserKeyParams{
-			UpdatedAt:       time.Now().Local(),
-			ApiKey:          hashedApiKey,
-			ApiKeyExpiresAt: keyExpiresAt,
-			ID:              user.ID,
-		})
-		if err != nil {
-			log.Println("Failed to update new apikey error: ", err)
-			return
-		}
+	userResp := map[string]any{
+		"message": "Signed in successfully",
+	}
+	if enrollmentRequired {
+		userResp["two_factor_enrollment_required"] = true
+	}
 
-		refreshToken, err := security.GenerateJWTToken(userID, cfg.RefreshSecret, keyExpiresAt)
-		if err != nil {
-			log.Println("Couldn't generate refresh token error: ", err)
-			return
-		}
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
 
-		err = queriesTx.UpdateUserTK(r.Context(), database.UpdateUserTKParams{
-			UpdatedAt:             time.Now().Local(),
-			AccessTokenExpiresAt:  jwtExpiresAt,
-			RefreshToken:          refreshToken,
-			RefreshTokenExpiresAt: keyExpiresAt,
-			UserID:                user.ID,
-		})
-		if err != nil {
-			if err == sql.ErrNoRows {
-				err = queriesTx.CreateUserRfKey(r.Context(), database.CreateUserRfKeyParams{
-					ID:                    uuid.New().String(),
-					CreatedAt:             time.Now().Local(),
-					UpdatedAt:             time.Now().Local(),
-					AccessTokenExpiresAt:  jwtExpiresAt,
-					RefreshToken:          refreshToken,
-					RefreshTokenExpiresAt: keyExpiresAt,
-					UserID:                user.ID,
-				})
-				if err != nil {
-					log.Println("Failed to create new refresh token error: ", err)
-					return
-				}
-			} else {
-				log.Println("Failed to update new refresh token error: ", err)
-				return
-			}
-		}
+// recordFailedSignin counts a wrong password against the account and locks
+// it once maxFailedSignins is reached.
+func recordFailedSignin(ctx context.Context, cfg *config.ApiConfig, userID string) error {
+	attempts, err := cfg.DB.RecordFailedLogin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if attempts < maxFailedSignins {
+		return nil
+	}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token",
-			Value:    tokenString,
-			Expires:  jwtExpiresAt,
-			HttpOnly: true,
-			Secure:   true,
-			Path:     "/",
-			// SameSite: http.SameSiteStrictMode,
-			SameSite: http.SameSiteLaxMode,
-		})
-
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshToken,
-			Expires:  keyExpiresAt,
-			HttpOnly: true,
-			Secure:   true,
-			Path:     "/",
-			// SameSite: http.SameSiteStrictMode,
-			SameSite: http.SameSiteLaxMode,
-		})
-
-		userResp := map[string]string{
-			"message": "Signed in successfully",
-		}
+	return cfg.DB.LockUser(ctx, database.LockUserParams{
+		LockedUntil: sql.NullTime{Time: time.Now().UTC().Add(accountLockDuration), Valid: true},
+		ID:          userID,
+	})
+}
+
+// isSigninLocked reports whether user is inside a lockout. locked_until has
+// no time zone; it's written in UTC, which is how lib/pq reads it back.
+func isSigninLocked(user database.User) bool {
+	return user.LockedUntil.Valid && user.LockedUntil.Time.After(time.Now().UTC())
+}
+
+// upgradePasswordHash re-hashes the password with the current settings.
+// Failure only means the upgrade is retried at the next sign-in.
+func upgradePasswordHash(ctx context.Context, cfg *config.ApiConfig, userID, password string) {
+	hashedPassword, err := cfg.PasswordHasher.Hash(password)
+	if err != nil {
+		logging.FromContext(ctx).Warn("couldn't rehash password", "error", err)
+		return
+	}
 
-		middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	if err := cfg.DB.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		UpdatedAt: time.Now().Local(),
+		Password:  hashedPassword,
+		ID:        userID,
+	}); err != nil {
+		logging.FromContext(ctx).Warn("couldn't store upgraded password hash", "error", err)
 	}
 }
+
+func signinLockedError(wait time.Duration) error {
+	return middlewares.TooManyRequestsError("signin_locked", "Too many failed sign-in attempts, please try again later", wait)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func signin(t *testing.T, cfg *config.ApiConfig, username, password string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := jsonRequest(t, http.MethodPost, "/v1/user/signin", map[string]string{
+		"username": username,
+		"password": password,
+	})
+	middlewares.Handle(cfg, HandlerSignin)(rec, req)
+	return rec
+}
+
+func TestSigninLockedAccountLooksLikeWrongPassword(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	user.LockedUntil = sql.NullTime{Time: time.Now().UTC().Add(10 * time.Minute), Valid: true}
+
+	mock.ExpectQuery("GetUserByUsername").WithArgs("nobody").WillReturnError(sql.ErrNoRows)
+	unknown := signin(t, cfg, "nobody", "password-1")
+
+	// No failed attempt is recorded while the lock is on.
+	mock.ExpectQuery("GetUserByUsername").WithArgs(user.Username).WillReturnRows(userRows(user))
+	locked := signin(t, cfg, user.Username, "wrong-password")
+
+	assert.Equal(t, http.StatusUnauthorized, locked.Code)
+	assert.Equal(t, unknown.Code, locked.Code)
+	assert.Empty(t, locked.Header().Get("Retry-After"))
+	assert.Equal(t, decodeProblem(t, unknown).Code, decodeProblem(t, locked).Code)
+}
+
+func TestSigninLockedAccountWithCorrectPassword(t *testing.T) {
+	setLocalZone(t, time.FixedZone("UTC+7", 7*60*60))
+
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	user.LockedUntil = sql.NullTime{Time: time.Now().UTC().Add(10 * time.Minute), Valid: true}
+
+	mock.ExpectQuery("GetUserByUsername").WithArgs(user.Username).WillReturnRows(userRows(user))
+	rec := signin(t, cfg, user.Username, "password-1")
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "signin_locked", decodeProblem(t, rec).Code)
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 10*60, retryAfter, 5)
+}
+
+func TestSigninLocksAfterRepeatedFailures(t *testing.T) {
+	// West of UTC, a lock stored as local wall-clock time has already
+	// expired by the time it is read back.
+	setLocalZone(t, time.FixedZone("UTC-5", -5*60*60))
+
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	user.FailedLoginAttempts = maxFailedSignins - 1
+
+	lockedUntil := &captureArg{}
+	mock.ExpectQuery("GetUserByUsername").WithArgs(user.Username).WillReturnRows(userRows(user))
+	mock.ExpectQuery("RecordFailedLogin").WithArgs(user.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"failed_login_attempts"}).AddRow(maxFailedSignins))
+	mock.ExpectExec("LockUser").WithArgs(lockedUntil, user.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := signin(t, cfg, user.Username, "wrong-password")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	stored := storedTimestamp(t, lockedUntil.value)
+	assert.WithinDuration(t, time.Now().Add(accountLockDuration), stored, 5*time.Second)
+
+	user.LockedUntil = sql.NullTime{Time: stored, Valid: true}
+	assert.True(t, isSigninLocked(user))
+}
+
+func TestSigninDoesNotRehashOverlongBcryptPasswords(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	password := strings.Repeat("p", security.BcryptMaxPasswordBytes)
+	user := newTestUser(t, cfg, password)
+
+	argon, err := security.NewPasswordHasher(security.HashAlgorithmArgon2id, bcrypt.MinCost,
+		security.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	require.NoError(t, err)
+	cfg.PasswordHasher = argon
+	execs := &execRecorder{DBTX: cfg.DBConn}
+	cfg.DB = database.New(execs)
+
+	expectSession := func() {
+		mock.ExpectQuery("GetRolePolicy").WithArgs(user.Role).
+			WillReturnRows(sqlmock.NewRows([]string{"role", "updated_at", "require_totp"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UpdateUserKey").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UpdateUserTK").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	// bcrypt ignores the extra bytes, so this signs in, but an argon2id
+	// hash of the longer string would lock out the real password.
+	mock.ExpectQuery("GetUserByUsername").WithArgs(user.Username).WillReturnRows(userRows(user))
+	expectSession()
+	rec := signin(t, cfg, user.Username, password+"-extra")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, execs.names(), "UpdateUserPassword")
+
+	mock.ExpectQuery("GetUserByUsername").WithArgs(user.Username).WillReturnRows(userRows(user))
+	mock.ExpectExec("UpdateUserPassword").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSession()
+	rec = signin(t, cfg, user.Username, password)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, execs.names(), "UpdateUserPassword")
+}
+
+// execRecorder notes the sqlc query name of every statement executed
+// outside a transaction. Unlike sqlmock's expectations it also sees
+// statements whose failure the handler only logs.
+type execRecorder struct {
+	database.DBTX
+	mu    sync.Mutex
+	execs []string
+}
+
+func (r *execRecorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(query, "-- name: "), " ")
+	r.mu.Lock()
+	r.execs = append(r.execs, name)
+	r.mu.Unlock()
+	return r.DBTX.ExecContext(ctx, query, args...)
+}
+
+func (r *execRecorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.execs)
+}
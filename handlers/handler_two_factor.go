@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/internal/metrics"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+)
+
+const (
+	twoFactorTokenTTL = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+func HandlerTwoFactorStatus(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type statusResponse struct {
+		Enabled                bool  `json:"enabled"`
+		Required               bool  `json:"required"`
+		RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+	}
+
+	required, err := middlewares.RoleRequiresTwoFactor(r.Context(), cfg, user.Role)
+	if err != nil {
+		return middlewares.InternalError("Couldn't get two-factor status", fmt.Errorf("get role policy: %w", err))
+	}
+
+	resp := statusResponse{
+		Enabled:  user.TotpEnabledAt.Valid,
+		Required: required,
+	}
+	if resp.Enabled {
+		resp.RecoveryCodesRemaining, err = cfg.DB.CountUnusedRecoveryCodes(r.Context(), user.ID)
+		if err != nil {
+			return middlewares.InternalError("Couldn't get two-factor status", fmt.Errorf("count recovery codes: %w", err))
+		}
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// HandlerTwoFactorEnroll stores a fresh secret for the user. It stays inactive
+// until HandlerTwoFactorConfirm sees a valid code generated from it. Accounts
+// created through OIDC send a reauth_token instead of current_password.
+func HandlerTwoFactorEnroll(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		ReauthToken     string `json:"reauth_token"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if user.TotpEnabledAt.Valid {
+		return middlewares.ConflictError("two_factor_already_enabled", "Two-factor authentication is already enabled")
+	}
+
+	if err := verifyReauthentication(r.Context(), cfg, user, params.CurrentPassword, params.ReauthToken); err != nil {
+		return err
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return middlewares.InternalError("Couldn't start two-factor enrolment", fmt.Errorf("generate totp secret: %w", err))
+	}
+
+	err = cfg.DB.SetUserTotpSecret(r.Context(), database.SetUserTotpSecretParams{
+		UpdatedAt:  time.Now().Local(),
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		ID:         user.ID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't start two-factor enrolment", fmt.Errorf("store totp secret: %w", err))
+	}
+
+	userResp := map[string]string{
+		"secret":      secret,
+		"otpauth_uri": security.TOTPProvisioningURI(cfg.TOTPIssuer, user.Email, secret),
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+func HandlerTwoFactorConfirm(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if user.TotpEnabledAt.Valid {
+		return middlewares.ConflictError("two_factor_already_enabled", "Two-factor authentication is already enabled")
+	}
+	if !user.TotpSecret.Valid {
+		return middlewares.ValidationError("two_factor_enrollment_not_started", "Two-factor enrolment has not been started")
+	}
+
+	step, ok := security.ValidateTOTPCode(user.TotpSecret.String, params.Code, time.Now(), 0)
+	if !ok {
+		return middlewares.ValidationError("invalid_two_factor_code", "Invalid two-factor code")
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't enable two-factor authentication", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	queriesTx := withTx(tx)
+	now := time.Now().Local()
+
+	if err := queriesTx.EnableUserTotp(r.Context(), database.EnableUserTotpParams{
+		UpdatedAt:       now,
+		TotpEnabledAt:   sql.NullTime{Time: now, Valid: true},
+		TotpLastCounter: step,
+		ID:              user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't enable two-factor authentication", fmt.Errorf("enable totp: %w", err))
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(r.Context(), queriesTx, user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't enable two-factor authentication", fmt.Errorf("create recovery codes: %w", err))
+	}
+
+	// Sessions that only passed the password check are signed out; this
+	// client gets a new session below.
+	if err := queriesTx.UpdateUserTokensInvalidBefore(r.Context(), database.UpdateUserTokensInvalidBeforeParams{
+		UpdatedAt:           now,
+		TokensInvalidBefore: tokensInvalidBeforeNow(),
+		ID:                  user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't enable two-factor authentication", fmt.Errorf("revoke other sessions: %w", err))
+	}
+
+	session, err := issueSession(r.Context(), cfg, queriesTx, user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't enable two-factor authentication", fmt.Errorf("issue session: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't enable two-factor authentication", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	setSessionCookies(w, session)
+
+	userResp := map[string]any{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": recoveryCodes,
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+func HandlerTwoFactorDisable(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		ReauthToken     string `json:"reauth_token"`
+		Code            string `json:"code"`
+		RecoveryCode    string `json:"recovery_code"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return middlewares.ValidationError("two_factor_not_enabled", "Two-factor authentication is not enabled")
+	}
+
+	required, err := middlewares.RoleRequiresTwoFactor(r.Context(), cfg, user.Role)
+	if err != nil {
+		return middlewares.InternalError("Couldn't disable two-factor authentication", fmt.Errorf("get role policy: %w", err))
+	}
+	if required {
+		return middlewares.ForbiddenError("two_factor_required", "Two-factor authentication is required for your role")
+	}
+
+	if err := verifyReauthentication(r.Context(), cfg, user, params.CurrentPassword, params.ReauthToken); err != nil {
+		return err
+	}
+
+	ok, err := verifySecondFactor(r.Context(), cfg, user, params.Code, params.RecoveryCode)
+	if err != nil {
+		return middlewares.InternalError("Couldn't disable two-factor authentication", fmt.Errorf("verify second factor: %w", err))
+	}
+	if !ok {
+		return middlewares.ValidationError("invalid_two_factor_code", "Invalid two-factor code")
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't disable two-factor authentication", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	queriesTx := withTx(tx)
+
+	if err := queriesTx.DisableUserTotp(r.Context(), database.DisableUserTotpParams{
+		UpdatedAt: time.Now().Local(),
+		ID:        user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't disable two-factor authentication", fmt.Errorf("disable totp: %w", err))
+	}
+
+	if err := queriesTx.DeleteRecoveryCodesByUserID(r.Context(), user.ID); err != nil {
+		return middlewares.InternalError("Couldn't disable two-factor authentication", fmt.Errorf("delete recovery codes: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't disable two-factor authentication", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	userResp := map[string]string{
+		"message": "Two-factor authentication disabled",
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+// HandlerRegenerateRecoveryCodes replaces every recovery code, used or not.
+// It asks for an authenticator code so a stolen session alone can't mint
+// new codes.
+func HandlerRegenerateRecoveryCodes(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return middlewares.ValidationError("two_factor_not_enabled", "Two-factor authentication is not enabled")
+	}
+
+	ok, err := verifySecondFactor(r.Context(), cfg, user, params.Code, "")
+	if err != nil {
+		return middlewares.InternalError("Couldn't regenerate recovery codes", fmt.Errorf("verify second factor: %w", err))
+	}
+	if !ok {
+		return middlewares.ValidationError("invalid_two_factor_code", "Invalid two-factor code")
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't regenerate recovery codes", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	recoveryCodes, err := replaceRecoveryCodes(r.Context(), withTx(tx), user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't regenerate recovery codes", fmt.Errorf("create recovery codes: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't regenerate recovery codes", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	userResp := map[string]any{
+		"recovery_codes": recoveryCodes,
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+// HandlerSigninTwoFactor completes a sign-in that HandlerSignin paused after
+// the password check, exchanging the mfa_token and a second factor for a
+// session.
+func HandlerSigninTwoFactor(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		MFAToken     string `json:"mfa_token" validate:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	claims, err := security.ValidateTwoFactorToken(params.MFAToken, cfg.JWTKeys, cfg.Token)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("invalid two-factor token", "error", err)
+		return middlewares.UnauthorizedError("invalid_mfa_token", "Invalid or expired two-factor token")
+	}
+
+	throttleKey := "2fa:" + claims.UserID.String()
+	if wait := cfg.LoginThrottle.Check(throttleKey); wait > 0 {
+		return signinLockedError(wait)
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), claims.UserID.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return middlewares.UnauthorizedError("invalid_mfa_token", "Invalid or expired two-factor token")
+		}
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("get user: %w", err))
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return middlewares.ValidationError("two_factor_not_enabled", "Two-factor authentication is not enabled")
+	}
+
+	if isSigninLocked(user) {
+		return signinLockedError(time.Until(user.LockedUntil.Time))
+	}
+
+	ok, err := verifySecondFactor(r.Context(), cfg, user, params.Code, params.RecoveryCode)
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("verify second factor: %w", err))
+	}
+	if !ok {
+		cfg.LoginThrottle.Failure(throttleKey)
+		cfg.Metrics.SigninFailed(metrics.SigninTwoFactor)
+		if err := recordFailedSignin(r.Context(), cfg, user.ID); err != nil {
+			logging.FromContext(r.Context()).Warn("couldn't record failed sign-in", "error", err)
+		}
+		return middlewares.UnauthorizedError("invalid_two_factor_code", "Invalid two-factor code")
+	}
+
+	if user.FailedLoginAttempts > 0 || user.LockedUntil.Valid {
+		if err := cfg.DB.ResetFailedLogins(r.Context(), user.ID); err != nil {
+			logging.FromContext(r.Context()).Warn("couldn't reset failed sign-ins", "error", err)
+		}
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	session, err := issueSession(r.Context(), cfg, withTx(tx), user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("issue session: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	setSessionCookies(w, session)
+	cfg.Metrics.Signin(metrics.SigninTwoFactor)
+
+	userResp := map[string]string{
+		"message": "Signed in successfully",
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+func respondTwoFactorRequired(w http.ResponseWriter, cfg *config.ApiConfig, userID string) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("invalid user id: %w", err))
+	}
+
+	mfaToken, err := security.GenerateTwoFactorToken(id, cfg.JWTKeys, cfg.Token, time.Now().Add(twoFactorTokenTTL))
+	if err != nil {
+		return middlewares.InternalError("Couldn't sign in", fmt.Errorf("generate two-factor token: %w", err))
+	}
+
+	userResp := map[string]any{
+		"two_factor_required": true,
+		"mfa_token":           mfaToken,
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+// verifySecondFactor checks either an authenticator code or a recovery code.
+// Both are consumed by a conditional update, so a code replayed concurrently
+// only succeeds once.
+func verifySecondFactor(ctx context.Context, cfg *config.ApiConfig, user database.User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		rows, err := cfg.DB.ConsumeRecoveryCode(ctx, database.ConsumeRecoveryCodeParams{
+			UsedAt:   sql.NullTime{Time: time.Now().Local(), Valid: true},
+			UserID:   user.ID,
+			CodeHash: security.HashToken(security.NormalizeRecoveryCode(recoveryCode)),
+		})
+		return rows == 1, err
+	}
+
+	step, ok := security.ValidateTOTPCode(user.TotpSecret.String, code, time.Now(), user.TotpLastCounter)
+	if !ok {
+		return false, nil
+	}
+
+	rows, err := cfg.DB.UpdateUserTotpCounter(ctx, database.UpdateUserTotpCounterParams{
+		TotpLastCounter: step,
+		ID:              user.ID,
+	})
+	return rows == 1, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, queries *database.Queries, userID string) ([]string, error) {
+	codes, err := security.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := queries.DeleteRecoveryCodesByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Local()
+	for _, code := range codes {
+		if err := queries.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			ID:        uuid.New().String(),
+			CreatedAt: now,
+			CodeHash:  security.HashToken(code),
+			UserID:    userID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
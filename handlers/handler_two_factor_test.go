@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/models"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorConfirmRevokesOlderSessions(t *testing.T) {
+	setLocalZone(t, time.FixedZone("UTC+7", 7*60*60))
+
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	secret, err := security.GenerateTOTPSecret()
+	require.NoError(t, err)
+	user.TotpSecret = sql.NullString{String: secret, Valid: true}
+	code, err := security.GenerateTOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	oldToken := signAccessToken(t, cfg, user.ID, time.Now().Add(-time.Minute))
+
+	cutoff := &captureArg{}
+	expectGetUser(mock, user)
+	mock.ExpectBegin()
+	mock.ExpectExec("EnableUserTotp").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DeleteRecoveryCodesByUserID").WillReturnResult(sqlmock.NewResult(0, 0))
+	for range recoveryCodeCount {
+		mock.ExpectExec("CreateRecoveryCode").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("UpdateUserTokensInvalidBefore").
+		WithArgs(sqlmock.AnyArg(), cutoff, user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UpdateUserKey").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UpdateUserTK").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(jsonRequest(t, http.MethodPost, "/v1/user/2fa/confirm", map[string]string{"code": code}), oldToken)
+	middlewares.MiddlewareAuthAllowTwoFactorSetup(cfg, HandlerTwoFactorConfirm)(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	newToken := responseCookie(rec, "access_token")
+	require.NotEmpty(t, newToken)
+	user.TokensInvalidBefore = sql.NullTime{Time: storedTimestamp(t, cutoff.value), Valid: true}
+	user.TotpEnabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	probe := middlewares.MiddlewareAuth(cfg, probeHandler)
+
+	expectAuthenticated(mock, user)
+	rec = httptest.NewRecorder()
+	probe(rec, withAccessToken(httptest.NewRequest(http.MethodGet, "/v1/user", nil), newToken))
+	assert.Equal(t, http.StatusOK, rec.Code, "new session must be accepted: %s", rec.Body.String())
+
+	expectGetUser(mock, user)
+	rec = httptest.NewRecorder()
+	probe(rec, withAccessToken(httptest.NewRequest(http.MethodGet, "/v1/user", nil), oldToken))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, middlewares.AuthReasonSessionRevoked, decodeProblem(t, rec).Code)
+}
+
+func TestTwoFactorEnrollWithReauthToken(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	// Staff created through OIDC, held to the setup routes by the role
+	// policy: nobody knows the account's password.
+	user := newTestUser(t, cfg, uuid.NewString())
+	user.Role = models.RoleStaff
+	token, err := security.GenerateReauthToken(uuid.MustParse(user.ID), cfg.JWTKeys, cfg.Token, time.Now().Add(reauthTokenTTL))
+	require.NoError(t, err)
+
+	enroll := func(t *testing.T, body map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := withAccessToken(jsonRequest(t, http.MethodPost, "/v1/user/2fa/enroll", body), signAccessToken(t, cfg, user.ID, time.Now()))
+		middlewares.MiddlewareAuthAllowTwoFactorSetup(cfg, HandlerTwoFactorEnroll)(rec, req)
+		return rec
+	}
+
+	expectGetUser(mock, user)
+	mock.ExpectExec("SetUserTotpSecret").WillReturnResult(sqlmock.NewResult(0, 1))
+	rec := enroll(t, map[string]string{"reauth_token": token})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "otpauth://")
+
+	otherToken, err := security.GenerateReauthToken(uuid.New(), cfg.JWTKeys, cfg.Token, time.Now().Add(reauthTokenTTL))
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		body map[string]string
+		want middlewares.FieldError
+	}{
+		{"no confirmation", map[string]string{}, middlewares.FieldError{Field: "current_password", Code: "required"}},
+		{"another user's token", map[string]string{"reauth_token": otherToken}, middlewares.FieldError{Field: "reauth_token", Code: "invalid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectGetUser(mock, user)
+			rec := enroll(t, tt.body)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			errs := decodeProblem(t, rec).Errors
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.want.Field, errs[0].Field)
+			assert.Equal(t, tt.want.Code, errs[0].Code)
+		})
+	}
+}
+
+func TestTwoFactorDisableWithReauthToken(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, uuid.NewString())
+	secret, err := security.GenerateTOTPSecret()
+	require.NoError(t, err)
+	user.TotpSecret = sql.NullString{String: secret, Valid: true}
+	user.TotpEnabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	code, err := security.GenerateTOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	token, err := security.GenerateReauthToken(uuid.MustParse(user.ID), cfg.JWTKeys, cfg.Token, time.Now().Add(reauthTokenTTL))
+	require.NoError(t, err)
+
+	expectGetUser(mock, user)
+	mock.ExpectQuery("GetRolePolicy").WithArgs(user.Role).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "updated_at", "require_totp"}))
+	mock.ExpectExec("UpdateUserTotpCounter").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("DisableUserTotp").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DeleteRecoveryCodesByUserID").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(jsonRequest(t, http.MethodPost, "/v1/user/2fa/disable", map[string]string{
+		"reauth_token": token,
+		"code":         code,
+	}), signAccessToken(t, cfg, user.ID, time.Now()))
+	middlewares.MiddlewareAuth(cfg, HandlerTwoFactorDisable)(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
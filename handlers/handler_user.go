@@ -1,185 +1,158 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/STaninnat/booking-backend/internal/config"
 	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
 	"github.com/STaninnat/booking-backend/middlewares"
 	"github.com/STaninnat/booking-backend/security"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HandlerCreateUser(cfg *config.ApiConfig) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		type parameters struct {
-			FirstName string `json:"firstname"`
-			LastName  string `json:"lastname"`
-			Email     string `json:"email"`
-			UserName  string `json:"username"`
-			Password  string `json:"password"`
-		}
-
-		defer r.Body.Close()
-		decoder := json.NewDecoder(r.Body)
-		params := parameters{}
-		if err := decoder.Decode(&params); err != nil {
-			log.Println("Decode error: ", err)
-			return
-		}
-
-		if params.FirstName == "" || params.LastName == "" || params.Email == "" || params.UserName == "" || params.Password == "" {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "Invalid input")
-			return
-		}
+func HandlerCreateUser(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		FirstName string `json:"firstname" validate:"required,max=50"`
+		LastName  string `json:"lastname" validate:"required,max=50"`
+		Email     string `json:"email" validate:"required,email"`
+		UserName  string `json:"username" validate:"required,username"`
+		Password  string `json:"password" validate:"required"`
+	}
 
-		if !security.IsValidUserNameFormat(params.UserName) {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "Invalid username format")
-			return
-		}
-		exists, err := cfg.DB.CheckUserExistsByUsername(r.Context(), params.UserName)
-		if err != nil {
-			log.Println("Checking username error: ", err)
-			return
-		}
-		if exists {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "Username already exists")
-			return
-		}
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
 
-		if !security.IsValidateEmailFormat(params.Email) {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "Invalid email format")
-			return
-		}
+	exists, err := cfg.DB.CheckUserExistsByUsername(r.Context(), params.UserName)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("check username: %w", err))
+	}
+	if exists {
+		return middlewares.ConflictError("username_taken", "Username already exists")
+	}
 
-		exists, err = cfg.DB.CheckUserExistsByEmail(r.Context(), params.Email)
-		if err != nil {
-			log.Println("Checking email error: ", err)
-			return
-		}
-		if exists {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "An account with this email already exists")
-			return
-		}
+	exists, err = cfg.DB.CheckUserExistsByEmail(r.Context(), params.Email)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("check email: %w", err))
+	}
+	if exists {
+		return middlewares.ConflictError("email_taken", "An account with this email already exists")
+	}
 
-		fullName := params.FirstName + " " + params.LastName
-		exists, err = cfg.DB.CheckUserExistsByFullname(r.Context(), fullName)
-		if err != nil {
-			log.Println("Checking full name error: ", err)
-			return
-		}
-		if exists {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "An account with this name already exists")
-			return
-		}
+	fullName := params.FirstName + " " + params.LastName
+	exists, err = cfg.DB.CheckUserExistsByFullname(r.Context(), fullName)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("check full name: %w", err))
+	}
+	if exists {
+		return middlewares.ConflictError("name_taken", "An account with this name already exists")
+	}
 
-		if len(params.Password) < 8 {
-			middlewares.RespondWithError(w, http.StatusBadRequest, "Password must be at least 8 ")
-			return
-		}
+	if err := cfg.PasswordPolicy.Validate(params.Password, params.UserName, params.Email); err != nil {
+		return passwordPolicyError("password", err)
+	}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
-		if err != nil {
-			log.Println("Couldn't hash password error: ", err)
-			return
-		}
+	hashedPassword, err := cfg.PasswordHasher.Hash(params.Password)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("hash password: %w", err))
+	}
 
-		_, hashedApiKey, err := security.GenerateAndHashAPIKey()
-		if err != nil {
-			log.Println("Couldn't generate apikey error: ", err)
-			return
-		}
+	_, hashedApiKey, err := security.GenerateAndHashAPIKey()
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("generate apikey: %w", err))
+	}
 
-		apiKeyExpiresAt := time.Now().Local().Add(30 * 24 * time.Hour)
-
-		err = cfg.DB.CreateUser(r.Context(), database.CreateUserParams{
-			ID:              uuid.New().String(),
-			CreatedAt:       time.Now().Local(),
-			UpdatedAt:       time.Now().Local(),
-			FullName:        fullName,
-			Email:           params.Email,
-			Username:        params.UserName,
-			Password:        string(hashedPassword),
-			ApiKey:          hashedApiKey,
-			ApiKeyExpiresAt: apiKeyExpiresAt,
-		})
-		if err != nil {
-			log.Printf("Error while creating user: %v\n", err)
-			middlewares.RespondWithError(w, http.StatusInternalServerError, "Couldn't create user")
-			return
-		}
+	apiKeyExpiresAt := time.Now().Local().Add(cfg.Token.RefreshTokenTTL)
+
+	err = cfg.DB.CreateUser(r.Context(), database.CreateUserParams{
+		ID:              uuid.New().String(),
+		CreatedAt:       time.Now().Local(),
+		UpdatedAt:       time.Now().Local(),
+		FullName:        fullName,
+		Email:           params.Email,
+		Username:        params.UserName,
+		Password:        hashedPassword,
+		ApiKey:          hashedApiKey,
+		ApiKeyExpiresAt: apiKeyExpiresAt,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("create user: %w", err))
+	}
 
-		jwtExpiresAt := time.Now().Local().Add(15 * time.Minute)
+	jwtExpiresAt := time.Now().Local().Add(cfg.Token.AccessTokenTTL)
 
-		user, err := cfg.DB.GetUserByKey(r.Context(), hashedApiKey)
-		if err != nil {
-			log.Printf("Error while getting user: %v\n", err)
-			return
-		}
+	user, err := cfg.DB.GetUserByKey(r.Context(), hashedApiKey)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("get user: %w", err))
+	}
 
-		userID, err := uuid.Parse(user.ID)
-		if err != nil {
-			log.Printf("Error parsing user ID: %v\n", err)
-			return
-		}
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("parse user id: %w", err))
+	}
 
-		tokenString, err := security.GenerateJWTToken(userID, cfg.JWTSecret, jwtExpiresAt)
-		if err != nil {
-			log.Println("Couldn't generate access token error: ", err)
-			return
+	if claimed, err := claimVerificationEmailSlot(r.Context(), cfg, user.ID); err != nil {
+		logging.FromContext(r.Context()).Warn("couldn't claim verification email slot", "error", err)
+	} else if claimed {
+		if err := sendVerificationEmail(r.Context(), cfg, user); err != nil {
+			logging.FromContext(r.Context()).Warn("couldn't send verification email", "error", err)
 		}
+	}
 
-		refreshExpiresAt := time.Now().Local().Add(30 * 24 * time.Hour)
-		refreshToken, err := security.GenerateJWTToken(userID, cfg.RefreshSecret, refreshExpiresAt)
-		if err != nil {
-			log.Println("Couldn't generate refresh token error: ", err)
-			return
-		}
+	tokenString, err := security.GenerateJWTToken(userID, cfg.JWTKeys, cfg.Token, jwtExpiresAt)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("generate access token: %w", err))
+	}
 
-		err = cfg.DB.CreateUserRfKey(r.Context(), database.CreateUserRfKeyParams{
-			ID:                    uuid.New().String(),
-			CreatedAt:             time.Now().Local(),
-			UpdatedAt:             time.Now().Local(),
-			AccessTokenExpiresAt:  jwtExpiresAt,
-			RefreshToken:          refreshToken,
-			RefreshTokenExpiresAt: refreshExpiresAt,
-			UserID:                user.ID,
-		})
-		if err != nil {
-			log.Println("Failed to create new refresh token error: ", err)
-			return
-		}
+	refreshExpiresAt := time.Now().Local().Add(cfg.Token.RefreshTokenTTL)
+	refreshToken, err := security.GenerateJWTToken(userID, cfg.RefreshKeys, cfg.Token, refreshExpiresAt)
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("generate refresh token: %w", err))
+	}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token",
-			Value:    tokenString,
-			HttpOnly: true,
-			Secure:   true,
-			Path:     "/",
-			Expires:  jwtExpiresAt,
-			// SameSite: http.SameSiteStrictMode,
-			SameSite: http.SameSiteLaxMode,
-		})
-
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshToken,
-			HttpOnly: true,
-			Secure:   true,
-			Path:     "/",
-			Expires:  refreshExpiresAt,
-			// SameSite: http.SameSiteStrictMode,
-			SameSite: http.SameSiteLaxMode,
-		})
-
-		userResp := map[string]string{
-			"message": "User created successfully",
-		}
+	err = cfg.DB.CreateUserRfKey(r.Context(), database.CreateUserRfKeyParams{
+		ID:                    uuid.New().String(),
+		CreatedAt:             time.Now().Local(),
+		UpdatedAt:             time.Now().Local(),
+		AccessTokenExpiresAt:  jwtExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt,
+		UserID:                user.ID,
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't create user", fmt.Errorf("create new refresh token: %w", err))
+	}
 
-		middlewares.RespondWithJSON(w, http.StatusCreated, userResp)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token",
+		Value:    tokenString,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		Expires:  jwtExpiresAt,
+		// SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		Expires:  refreshExpiresAt,
+		// SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	userResp := map[string]string{
+		"message": "User created successfully",
 	}
+
+	middlewares.RespondWithJSON(w, http.StatusCreated, userResp)
+	return nil
 }
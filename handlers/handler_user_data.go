@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+)
+
+type userDataExport struct {
+	ExportedAt     time.Time               `json:"exported_at"`
+	Profile        exportedProfile         `json:"profile"`
+	Security       exportedSecurity        `json:"security"`
+	Bookings       []exportedBooking       `json:"bookings"`
+	Identities     []exportedIdentity      `json:"linked_identities"`
+	PasswordResets []exportedPasswordReset `json:"password_resets"`
+}
+
+type exportedProfile struct {
+	ID              string     `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	FullName        string     `json:"full_name"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Phone           *string    `json:"phone"`
+	Role            string     `json:"role"`
+}
+
+type exportedSecurity struct {
+	TwoFactorEnabledAt     *time.Time `json:"two_factor_enabled_at"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+	FailedLoginAttempts    int32      `json:"failed_login_attempts"`
+	LockedUntil            *time.Time `json:"locked_until"`
+	SessionsRevokedAt      *time.Time `json:"sessions_revoked_at"`
+	SessionExpiresAt       *time.Time `json:"session_expires_at"`
+}
+
+type exportedBooking struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CheckIn   time.Time `json:"check_in"`
+	CheckOut  time.Time `json:"check_out"`
+	RoomID    string    `json:"room_id"`
+	RoomName  string    `json:"room_name"`
+}
+
+type exportedIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     *string   `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportedPasswordReset struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// HandlerExportUserData returns everything stored about the signed-in user.
+// Secrets (password hash, TOTP secret, token hashes) are left out: they are
+// credentials, not personal data the user can do anything with.
+func HandlerExportUserData(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	export := userDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: exportedProfile{
+			ID:              user.ID,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+			FullName:        user.FullName,
+			Username:        user.Username,
+			Email:           user.Email,
+			EmailVerifiedAt: nullTimePtr(user.EmailVerifiedAt),
+			Phone:           nullStringPtr(user.Phone),
+			Role:            user.Role,
+		},
+		Security: exportedSecurity{
+			TwoFactorEnabledAt:  nullTimePtr(user.TotpEnabledAt),
+			FailedLoginAttempts: user.FailedLoginAttempts,
+			LockedUntil:         nullTimePtr(user.LockedUntil),
+			SessionsRevokedAt:   nullTimePtr(user.TokensInvalidBefore),
+		},
+		Bookings:       []exportedBooking{},
+		Identities:     []exportedIdentity{},
+		PasswordResets: []exportedPasswordReset{},
+	}
+
+	if user.TotpEnabledAt.Valid {
+		remaining, err := cfg.DB.CountUnusedRecoveryCodes(r.Context(), user.ID)
+		if err != nil {
+			return middlewares.InternalError("Couldn't export user data", fmt.Errorf("count recovery codes: %w", err))
+		}
+		export.Security.RecoveryCodesRemaining = remaining
+	}
+
+	refreshKey, err := cfg.DB.GetRfKeyByUserID(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return middlewares.InternalError("Couldn't export user data", fmt.Errorf("get session: %w", err))
+	}
+	if err == nil {
+		export.Security.SessionExpiresAt = &refreshKey.RefreshTokenExpiresAt
+	}
+
+	bookings, err := cfg.DB.GetBookingsByUserID(r.Context(), user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't export user data", fmt.Errorf("get bookings: %w", err))
+	}
+	for _, booking := range bookings {
+		export.Bookings = append(export.Bookings, exportedBooking{
+			ID:        booking.ID,
+			UpdatedAt: booking.UpdatedAt,
+			CheckIn:   booking.CheckIn,
+			CheckOut:  booking.CheckOut,
+			RoomID:    booking.RoomID,
+			RoomName:  booking.RoomName,
+		})
+	}
+
+	identities, err := cfg.DB.GetUserIdentitiesByUserID(r.Context(), user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't export user data", fmt.Errorf("get linked identities: %w", err))
+	}
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, exportedIdentity{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     nullStringPtr(identity.Email),
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	resets, err := cfg.DB.GetPasswordResetsByUserID(r.Context(), user.ID)
+	if err != nil {
+		return middlewares.InternalError("Couldn't export user data", fmt.Errorf("get password resets: %w", err))
+	}
+	for _, reset := range resets {
+		export.PasswordResets = append(export.PasswordResets, exportedPasswordReset{
+			CreatedAt: reset.CreatedAt,
+			ExpiresAt: reset.ExpiresAt,
+			UsedAt:    nullTimePtr(reset.UsedAt),
+		})
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="booking-user-data.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	middlewares.RespondWithJSON(w, http.StatusOK, export)
+	return nil
+}
+
+// HandlerDeleteUser erases the account's personal data. The row itself is
+// kept, anonymised, so bookings stay in the books under a pseudonymous id.
+// Accounts created through OIDC have no password anyone knows, so they
+// confirm with the reauth_token from GET /v1/user/reauth/oidc/{provider}
+// instead.
+func HandlerDeleteUser(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		ReauthToken     string `json:"reauth_token"`
+		Code            string `json:"code"`
+		RecoveryCode    string `json:"recovery_code"`
+	}
+
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
+
+	if err := verifyReauthentication(r.Context(), cfg, user, params.CurrentPassword, params.ReauthToken); err != nil {
+		return err
+	}
+
+	if user.TotpEnabledAt.Valid {
+		ok, err := verifySecondFactor(r.Context(), cfg, user, params.Code, params.RecoveryCode)
+		if err != nil {
+			return middlewares.InternalError("Couldn't delete account", fmt.Errorf("verify second factor: %w", err))
+		}
+		if !ok {
+			return middlewares.ValidationError("invalid_two_factor_code", "Invalid two-factor code")
+		}
+	}
+
+	_, hashedApiKey, err := security.GenerateAndHashAPIKey()
+	if err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("generate apikey: %w", err))
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("start transaction: %w", err))
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logging.FromContext(r.Context()).Error("failed to rollback transaction", "error", err)
+		}
+	}()
+
+	queriesTx := withTx(tx)
+	now := time.Now().Local()
+	pseudonym := "deleted-" + user.ID
+
+	// The password is not a valid hash, so no password can ever match it.
+	// Replacing the API key and moving tokens_invalid_before ends every
+	// access token; deleted_at is UTC like the other instants.
+	if err := queriesTx.AnonymizeUser(r.Context(), database.AnonymizeUserParams{
+		UpdatedAt:           now,
+		DeletedAt:           sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		FullName:            "Deleted user",
+		Email:               pseudonym + "@deleted.invalid",
+		Username:            pseudonym,
+		Password:            "!",
+		ApiKey:              hashedApiKey,
+		ApiKeyExpiresAt:     now.AddDate(-1, 0, 0),
+		TokensInvalidBefore: tokensInvalidBeforeNow(),
+		ID:                  user.ID,
+	}); err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("anonymise user: %w", err))
+	}
+
+	if err := queriesTx.DeleteRecoveryCodesByUserID(r.Context(), user.ID); err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("delete recovery codes: %w", err))
+	}
+
+	if err := queriesTx.DeletePasswordResetsByUserID(r.Context(), user.ID); err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("delete password resets: %w", err))
+	}
+
+	if err := queriesTx.DeleteUserIdentitiesByUserID(r.Context(), user.ID); err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("delete linked identities: %w", err))
+	}
+
+	if err := expireRefreshToken(r.Context(), queriesTx, user.ID); err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("expire refresh token: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return middlewares.InternalError("Couldn't delete account", fmt.Errorf("commit transaction: %w", err))
+	}
+
+	clearSessionCookies(w)
+
+	userResp := map[string]string{
+		"message": "Account deleted",
+	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, userResp)
+	return nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
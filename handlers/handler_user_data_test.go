@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/oidc"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deleteUser(t *testing.T, cfg *config.ApiConfig, user database.User, body map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(jsonRequest(t, http.MethodDelete, "/v1/user", body), signAccessToken(t, cfg, user.ID, time.Now()))
+	middlewares.MiddlewareAuthAllowTwoFactorSetup(cfg, HandlerDeleteUser)(rec, req)
+	return rec
+}
+
+// accountDeletion holds the values HandlerDeleteUser writes that a test
+// may want to inspect.
+type accountDeletion struct {
+	deletedAt           *captureArg
+	tokensInvalidBefore *captureArg
+	refreshToken        *captureArg
+}
+
+// expectAccountDeletion sets up the deletion transaction. No statement
+// touches bookings: the strict mock fails the test if one does, so they
+// stay under the user id. The API key is replaced by AnonymizeUser alone.
+func expectAccountDeletion(mock sqlmock.Sqlmock, user database.User) accountDeletion {
+	pseudonym := "deleted-" + user.ID
+	deletion := accountDeletion{deletedAt: &captureArg{}, tokensInvalidBefore: &captureArg{}, refreshToken: &captureArg{}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("AnonymizeUser").
+		WithArgs(sqlmock.AnyArg(), deletion.deletedAt, "Deleted user", pseudonym+"@deleted.invalid", pseudonym, "!",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), deletion.tokensInvalidBefore, user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DeleteRecoveryCodesByUserID").WithArgs(user.ID).WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectExec("DeletePasswordResetsByUserID").WithArgs(user.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DeleteUserIdentitiesByUserID").WithArgs(user.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UpdateUserTK").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), deletion.refreshToken, sqlmock.AnyArg(), user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	return deletion
+}
+
+func TestDeleteUser(t *testing.T) {
+	setLocalZone(t, time.FixedZone("UTC+7", 7*60*60))
+
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	user.Phone = sql.NullString{String: "+66 123", Valid: true}
+	oldAccessToken := signAccessToken(t, cfg, user.ID, time.Now())
+	oldRefreshToken := "old-refresh-token"
+
+	expectGetUser(mock, user)
+	deletion := expectAccountDeletion(mock, user)
+
+	rec := deleteUser(t, cfg, user, map[string]string{"current_password": "password-1"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	for _, cookie := range rec.Result().Cookies() {
+		assert.Empty(t, cookie.Value, cookie.Name)
+		assert.Negative(t, cookie.MaxAge, cookie.Name)
+	}
+	assert.NotEqual(t, oldRefreshToken, deletion.refreshToken.value)
+
+	deletedAt := storedTimestamp(t, deletion.deletedAt.value)
+	tokensInvalidBefore := storedTimestamp(t, deletion.tokensInvalidBefore.value)
+	assert.WithinDuration(t, time.Now(), deletedAt, 5*time.Second)
+	assert.WithinDuration(t, time.Now(), tokensInvalidBefore, 5*time.Second)
+
+	pseudonym := "deleted-" + user.ID
+	deleted := user
+	deleted.FullName = "Deleted user"
+	deleted.Email = pseudonym + "@deleted.invalid"
+	deleted.Username = pseudonym
+	deleted.Phone = sql.NullString{}
+	deleted.Password = "!"
+	deleted.DeletedAt = sql.NullTime{Time: deletedAt, Valid: true}
+	deleted.TokensInvalidBefore = sql.NullTime{Time: tokensInvalidBefore, Valid: true}
+
+	t.Run("old access token", func(t *testing.T) {
+		expectGetUser(mock, deleted)
+		rec := httptest.NewRecorder()
+		middlewares.MiddlewareAuth(cfg, probeHandler)(rec, withAccessToken(httptest.NewRequest(http.MethodGet, "/v1/user", nil), oldAccessToken))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, middlewares.AuthReasonSessionRevoked, decodeProblem(t, rec).Code)
+	})
+
+	t.Run("old refresh token", func(t *testing.T) {
+		mock.ExpectQuery("GetUserByRfKey").WithArgs(oldRefreshToken).WillReturnError(sql.ErrNoRows)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/user/refresh-key", nil)
+		req.AddCookie(&http.Cookie{Name: "refresh_token", Value: oldRefreshToken})
+		middlewares.Handle(cfg, HandlerRefreshKey)(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("password sign-in", func(t *testing.T) {
+		mock.ExpectQuery("GetUserByUsername").WithArgs(user.Username).WillReturnError(sql.ErrNoRows)
+		rec := signin(t, cfg, user.Username, "password-1")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		// The pseudonymous username can't sign in either: "!" is no hash.
+		match, _, _ := cfg.PasswordHasher.Verify("!", deleted.Password)
+		assert.False(t, match)
+	})
+}
+
+func TestDeleteUserWithReauthToken(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	// An OIDC-provisioned account: nobody knows its password.
+	user := newTestUser(t, cfg, uuid.NewString())
+	token, err := security.GenerateReauthToken(uuid.MustParse(user.ID), cfg.JWTKeys, cfg.Token, time.Now().Add(reauthTokenTTL))
+	require.NoError(t, err)
+
+	expectGetUser(mock, user)
+	expectAccountDeletion(mock, user)
+
+	rec := deleteUser(t, cfg, user, map[string]string{"reauth_token": token})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestDeleteUserRejections(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	otherToken, err := security.GenerateReauthToken(uuid.New(), cfg.JWTKeys, cfg.Token, time.Now().Add(reauthTokenTTL))
+	require.NoError(t, err)
+	expiredToken, err := security.GenerateReauthToken(uuid.New(), cfg.JWTKeys, cfg.Token, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		body          map[string]string
+		expectedField string
+		expectedCode  string
+	}{
+		{"no confirmation", map[string]string{}, "current_password", "required"},
+		{"wrong password", map[string]string{"current_password": "wrong"}, "current_password", "incorrect"},
+		{"reauth token for another user", map[string]string{"reauth_token": otherToken}, "reauth_token", "invalid"},
+		{"expired reauth token", map[string]string{"reauth_token": expiredToken}, "reauth_token", "invalid"},
+		{"access token as reauth token", map[string]string{"reauth_token": signAccessToken(t, cfg, uuid.NewString(), time.Now())}, "reauth_token", "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectGetUser(mock, user)
+			rec := deleteUser(t, cfg, user, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			problem := decodeProblem(t, rec)
+			require.Len(t, problem.Errors, 1)
+			assert.Equal(t, tt.expectedField, problem.Errors[0].Field)
+			assert.Equal(t, tt.expectedCode, problem.Errors[0].Code)
+		})
+	}
+}
+
+func TestFinishOIDCReauth(t *testing.T) {
+	userID := uuid.NewString()
+	startedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name             string
+		authTime         *jwt.NumericDate
+		lookup           bool
+		identityUserID   string
+		expectedLocation string
+	}{
+		{"fresh login", jwt.NewNumericDate(time.Now()), true, userID, "/account/reauth#token="},
+		{"no auth_time", nil, false, "", "/signin?error=reauth_not_fresh"},
+		{"reused provider session", jwt.NewNumericDate(startedAt.Add(-time.Hour)), false, "", "/signin?error=reauth_not_fresh"},
+		{"identity of another user", jwt.NewNumericDate(time.Now()), true, uuid.NewString(), "/signin?error=reauth_mismatch"},
+		{"unlinked identity", jwt.NewNumericDate(time.Now()), true, "", "/signin?error=reauth_mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mock := newTestConfig(t)
+			switch {
+			case tt.lookup && tt.identityUserID == "":
+				mock.ExpectQuery("GetUserIdentity").WithArgs("example", "subject-1").WillReturnError(sql.ErrNoRows)
+			case tt.lookup:
+				mock.ExpectQuery("GetUserIdentity").WithArgs("example", "subject-1").WillReturnRows(
+					sqlmock.NewRows([]string{"id", "created_at", "provider", "subject", "email", "user_id"}).
+						AddRow(uuid.NewString(), time.Now(), "example", "subject-1", nil, tt.identityUserID))
+			}
+
+			state := &security.OIDCStateClaims{
+				ReauthUserID:     userID,
+				RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(startedAt)},
+			}
+			claims := &oidc.IDTokenClaims{
+				AuthTime:         tt.authTime,
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "subject-1"},
+			}
+
+			rec := httptest.NewRecorder()
+			finishOIDCReauth(rec, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/example/callback", nil), cfg, "example", state, claims)
+
+			require.Equal(t, http.StatusFound, rec.Code)
+			location := rec.Header().Get("Location")
+			assert.True(t, strings.HasPrefix(location, cfg.FrontendURL+tt.expectedLocation), location)
+
+			if tt.identityUserID == userID {
+				redirect, err := url.Parse(location)
+				require.NoError(t, err)
+				assert.Empty(t, redirect.RawQuery)
+				fragment, err := url.ParseQuery(redirect.Fragment)
+				require.NoError(t, err)
+				reauth, err := security.ValidateReauthToken(fragment.Get("token"), cfg.JWTKeys, cfg.Token)
+				require.NoError(t, err)
+				assert.Equal(t, userID, reauth.UserID.String())
+			}
+		})
+	}
+}
+
+func TestExportUserData(t *testing.T) {
+	cfg, mock := newTestConfig(t)
+	user := newTestUser(t, cfg, "password-1")
+	user.EmailVerifiedAt = sql.NullTime{Time: user.CreatedAt, Valid: true}
+	user.TotpEnabledAt = sql.NullTime{Time: user.CreatedAt, Valid: true}
+	user.TotpSecret = sql.NullString{String: "TOTPSECRET", Valid: true}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	expectGetUser(mock, user)
+	mock.ExpectQuery("CountUnusedRecoveryCodes").WithArgs(user.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	mock.ExpectQuery("GetRfKeyByUserID").WithArgs(user.ID).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at", "updated_at", "access_token_expires_at", "refresh_token", "refresh_token_expires_at", "user_id"}).
+			AddRow(uuid.NewString(), now, now, now, "refresh-token-secret", now.Add(24*time.Hour), user.ID))
+	mock.ExpectQuery("GetBookingsByUserID").WithArgs(user.ID).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "updated_at", "check_in", "check_out", "user_id", "room_id", "room_name"}).
+			AddRow("booking-1", now, now.AddDate(0, 0, 7), now.AddDate(0, 0, 9), user.ID, "room-1", "Sea View"))
+	mock.ExpectQuery("GetUserIdentitiesByUserID").WithArgs(user.ID).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at", "provider", "subject", "email", "user_id"}).
+			AddRow(uuid.NewString(), now, "example", "subject-1", "test@example.com", user.ID))
+	mock.ExpectQuery("GetPasswordResetsByUserID").WithArgs(user.ID).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at", "token_hash", "expires_at", "used_at", "user_id"}).
+			AddRow(uuid.NewString(), now, "reset-token-hash", now.Add(time.Hour), nil, user.ID))
+
+	rec := httptest.NewRecorder()
+	req := withAccessToken(httptest.NewRequest(http.MethodGet, "/v1/user/export", nil), signAccessToken(t, cfg, user.ID, time.Now()))
+	middlewares.MiddlewareAuthAllowTwoFactorSetup(cfg, HandlerExportUserData)(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	body := rec.Body.String()
+	for _, secret := range []string{user.Password, "TOTPSECRET", "refresh-token-secret", "reset-token-hash", user.ApiKey} {
+		assert.NotContains(t, body, secret)
+	}
+
+	var export map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	assert.ElementsMatch(t, []string{"exported_at", "profile", "security", "bookings", "linked_identities", "password_resets"}, keys(export))
+
+	profile := export["profile"].(map[string]any)
+	assert.ElementsMatch(t, []string{"id", "created_at", "updated_at", "full_name", "username", "email", "email_verified_at", "phone", "role"}, keys(profile))
+	assert.Equal(t, user.ID, profile["id"])
+	assert.Equal(t, user.Email, profile["email"])
+	assert.Nil(t, profile["phone"])
+
+	securityInfo := export["security"].(map[string]any)
+	assert.ElementsMatch(t, []string{"two_factor_enabled_at", "recovery_codes_remaining", "failed_login_attempts", "locked_until", "sessions_revoked_at", "session_expires_at"}, keys(securityInfo))
+	assert.EqualValues(t, 7, securityInfo["recovery_codes_remaining"])
+	assert.NotNil(t, securityInfo["session_expires_at"])
+
+	bookings := export["bookings"].([]any)
+	require.Len(t, bookings, 1)
+	assert.ElementsMatch(t, []string{"id", "updated_at", "check_in", "check_out", "room_id", "room_name"}, keys(bookings[0].(map[string]any)))
+	assert.Equal(t, "Sea View", bookings[0].(map[string]any)["room_name"])
+
+	identities := export["linked_identities"].([]any)
+	require.Len(t, identities, 1)
+	assert.ElementsMatch(t, []string{"provider", "subject", "email", "created_at"}, keys(identities[0].(map[string]any)))
+
+	resets := export["password_resets"].([]any)
+	require.Len(t, resets, 1)
+	assert.ElementsMatch(t, []string{"created_at", "expires_at", "used_at"}, keys(resets[0].(map[string]any)))
+}
+
+func keys(m map[string]any) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
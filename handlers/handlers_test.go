@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/mailer"
+	"github.com/STaninnat/booking-backend/internal/models"
+	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Handlers report failures by returning an error that middlewares.Handle or
+// MiddlewareAuth turns into a response. With every handler returning error
+// the compiler rejects a path that falls off the end, and the adapter
+// answers a nil return that wrote nothing with a 500.
+func TestHandlersReturnError(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+
+	checked := 0
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Handler") {
+					continue
+				}
+				checked++
+
+				results := fn.Type.Results
+				if !assert.NotNil(t, results, "%s must return error", fn.Name.Name) {
+					continue
+				}
+				assert.Len(t, results.List, 1, "%s must return only error", fn.Name.Name)
+				ident, ok := results.List[0].Type.(*ast.Ident)
+				assert.True(t, ok && ident.Name == "error", "%s must return error", fn.Name.Name)
+			}
+		}
+	}
+
+	assert.NotZero(t, checked)
+}
+
+// newTestConfig returns a config whose database is a sqlmock, for driving
+// handlers and the auth middleware through expected queries.
+func newTestConfig(t *testing.T) (*config.ApiConfig, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	jwtKeys, err := security.NewHMACKeyRing(security.LegacyHMACKeyID, "test-jwt-secret-at-least-32-bytes!")
+	require.NoError(t, err)
+	refreshKeys, err := security.NewHMACKeyRing(security.LegacyHMACKeyID, "test-refresh-secret-at-least-32-bytes")
+	require.NoError(t, err)
+	hasher, err := security.NewPasswordHasher(security.HashAlgorithmBcrypt, bcrypt.MinCost, security.Argon2Params{})
+	require.NoError(t, err)
+	policy, err := security.NewPasswordPolicy(8, 72, nil)
+	require.NoError(t, err)
+
+	return &config.ApiConfig{
+		DB:          database.New(db),
+		DBConn:      db,
+		JWTKeys:     jwtKeys,
+		RefreshKeys: refreshKeys,
+		Token: security.TokenSettings{
+			Issuer:          "booking-api",
+			Audience:        "booking-frontend",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 24 * time.Hour,
+		},
+		Mailer:         &recordingMailer{},
+		FrontendURL:    "https://booking.example.com",
+		LoginThrottle:  security.NewLoginThrottle(100, time.Second, time.Minute, time.Hour),
+		Background:     &sync.WaitGroup{},
+		PasswordPolicy: policy,
+		PasswordHasher: hasher,
+	}, mock
+}
+
+func newTestUser(t *testing.T, cfg *config.ApiConfig, password string) database.User {
+	t.Helper()
+
+	hashed, err := cfg.PasswordHasher.Hash(password)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	return database.User{
+		ID:              uuid.New().String(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		FullName:        "Test User",
+		Email:           "test@example.com",
+		Username:        "testuser",
+		Password:        hashed,
+		ApiKey:          "hashed-api-key",
+		ApiKeyExpiresAt: now.Add(24 * time.Hour),
+		Role:            models.RoleGuest,
+	}
+}
+
+// expectAuthenticated sets up the queries MiddlewareAuth runs for user.
+func expectAuthenticated(mock sqlmock.Sqlmock, user database.User) {
+	expectGetUser(mock, user)
+	if !user.TotpEnabledAt.Valid {
+		mock.ExpectQuery("GetRolePolicy").WithArgs(user.Role).
+			WillReturnRows(sqlmock.NewRows([]string{"role", "updated_at", "require_totp"}))
+	}
+}
+
+func expectGetUser(mock sqlmock.Sqlmock, user database.User) {
+	mock.ExpectQuery("GetUserByID").WithArgs(user.ID).WillReturnRows(userRows(user))
+}
+
+// userRows returns user as the row the users queries select.
+func userRows(user database.User) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "created_at", "updated_at", "full_name", "email", "phone", "username", "password",
+		"api_key", "api_key_expires_at", "email_verified_at", "email_verification_sent_at",
+		"tokens_invalid_before", "role", "failed_login_attempts", "locked_until", "totp_secret",
+		"totp_enabled_at", "totp_last_counter", "deleted_at",
+	}).AddRow(
+		user.ID, user.CreatedAt, user.UpdatedAt, user.FullName, user.Email, dbValue(user.Phone), user.Username, user.Password,
+		user.ApiKey, user.ApiKeyExpiresAt, dbValue(user.EmailVerifiedAt), dbValue(user.EmailVerificationSentAt),
+		dbValue(user.TokensInvalidBefore), user.Role, user.FailedLoginAttempts, dbValue(user.LockedUntil), dbValue(user.TotpSecret),
+		dbValue(user.TotpEnabledAt), user.TotpLastCounter, dbValue(user.DeletedAt),
+	)
+}
+
+// dbValue returns what the driver would hand back for a nullable column.
+func dbValue(v driver.Valuer) driver.Value {
+	value, _ := v.Value()
+	return value
+}
+
+// captureArg matches any query argument and keeps it for later assertions.
+type captureArg struct {
+	value driver.Value
+}
+
+func (c *captureArg) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+// storedTimestamp returns t as it reads back from a TIMESTAMP column:
+// Postgres keeps the wall clock lib/pq sends, dropping the offset, and
+// lib/pq labels the result UTC.
+func storedTimestamp(t *testing.T, v driver.Value) time.Time {
+	t.Helper()
+
+	ts, ok := v.(time.Time)
+	require.True(t, ok, "expected a time.Time argument, got %T", v)
+	return time.Date(ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.UTC)
+}
+
+// setLocalZone runs the rest of the test with time.Local set to loc, so
+// code mixing local and UTC timestamps shows up on any host.
+func setLocalZone(t *testing.T, loc *time.Location) {
+	t.Helper()
+
+	previous := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = previous })
+}
+
+// signAccessToken returns an access token for userID issued at issuedAt.
+func signAccessToken(t *testing.T, cfg *config.ApiConfig, userID string, issuedAt time.Time) string {
+	t.Helper()
+
+	token, err := cfg.JWTKeys.Sign(security.Claims{
+		UserID: uuid.MustParse(userID),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.Token.Issuer,
+			Audience:  []string{cfg.Token.Audience},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.Token.AccessTokenTTL)),
+		},
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func jsonRequest(t *testing.T, method, target string, body any) *http.Request {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func withAccessToken(req *http.Request, token string) *http.Request {
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	return req
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) string {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) middlewares.Problem {
+	t.Helper()
+
+	var problem middlewares.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+	return problem
+}
+
+// recordingMailer keeps every message instead of sending it.
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *recordingMailer) sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.messages...)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/tracing"
+)
+
+// withTx returns queries that run inside tx. Use it instead of
+// Queries.WithTx, which would bypass the tracing wrapper around cfg.DB.
+func withTx(tx *sql.Tx) *database.Queries {
+	return database.New(tracing.WrapDB(tx))
+}
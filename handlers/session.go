@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+)
+
+type userSession struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// issueSession rotates the user's API key and refresh token and returns a
+// fresh token pair. Callers wanting it to be atomic with other writes pass
+// transaction-bound queries.
+func issueSession(ctx context.Context, cfg *config.ApiConfig, queries *database.Queries, userID string) (userSession, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return userSession{}, fmt.Errorf("couldn't parse user id: %w", err)
+	}
+
+	jwtExpiresAt := time.Now().Local().Add(cfg.Token.AccessTokenTTL)
+	keyExpiresAt := time.Now().Local().Add(cfg.Token.RefreshTokenTTL)
+
+	accessToken, err := security.GenerateJWTToken(parsedUserID, cfg.JWTKeys, cfg.Token, jwtExpiresAt)
+	if err != nil {
+		return userSession{}, fmt.Errorf("couldn't generate access token: %w", err)
+	}
+
+	_, hashedApiKey, err := security.GenerateAndHashAPIKey()
+	if err != nil {
+		return userSession{}, fmt.Errorf("couldn't generate apikey: %w", err)
+	}
+
+	err = queries.UpdateUserKey(ctx, database.UpdateUserKeyParams{
+		UpdatedAt:       time.Now().Local(),
+		ApiKey:          hashedApiKey,
+		ApiKeyExpiresAt: keyExpiresAt,
+		ID:              userID,
+	})
+	if err != nil {
+		return userSession{}, fmt.Errorf("couldn't update apikey: %w", err)
+	}
+
+	refreshToken, err := security.GenerateJWTToken(parsedUserID, cfg.RefreshKeys, cfg.Token, keyExpiresAt)
+	if err != nil {
+		return userSession{}, fmt.Errorf("couldn't generate refresh token: %w", err)
+	}
+
+	err = queries.UpdateUserTK(ctx, database.UpdateUserTKParams{
+		UpdatedAt:             time.Now().Local(),
+		AccessTokenExpiresAt:  jwtExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: keyExpiresAt,
+		UserID:                userID,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return userSession{}, fmt.Errorf("couldn't update refresh token: %w", err)
+		}
+
+		err = queries.CreateUserRfKey(ctx, database.CreateUserRfKeyParams{
+			ID:                    uuid.New().String(),
+			CreatedAt:             time.Now().Local(),
+			UpdatedAt:             time.Now().Local(),
+			AccessTokenExpiresAt:  jwtExpiresAt,
+			RefreshToken:          refreshToken,
+			RefreshTokenExpiresAt: keyExpiresAt,
+			UserID:                userID,
+		})
+		if err != nil {
+			return userSession{}, fmt.Errorf("couldn't create refresh token: %w", err)
+		}
+	}
+
+	return userSession{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  jwtExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: keyExpiresAt,
+	}, nil
+}
+
+func setSessionCookies(w http.ResponseWriter, session userSession) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token",
+		Value:    session.AccessToken,
+		Expires:  session.AccessTokenExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		// SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    session.RefreshToken,
+		Expires:  session.RefreshTokenExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		// SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	expiredAt := time.Now().Local().AddDate(-1, 0, 0)
+
+	for _, name := range []string{"access_token", "refresh_token"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Expires:  expiredAt,
+			MaxAge:   -1,
+			HttpOnly: true,
+			Path:     "/",
+			Secure:   true,
+			// SameSite: http.SameSiteStrictMode,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// tokensInvalidBeforeNow returns the users.tokens_invalid_before value that
+// revokes every access token issued so far. The column has no time zone and
+// lib/pq reads it back as UTC, so it's written in UTC as well; it's
+// truncated to the second because that's the precision of a token's iat.
+func tokensInvalidBeforeNow() sql.NullTime {
+	return sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+}
+
+// revokeUserSessions expires the user's API key and replaces the refresh
+// token so every outstanding access and refresh token stops working.
+func revokeUserSessions(ctx context.Context, queries *database.Queries, userID string) error {
+	expiredAt := time.Now().Local().AddDate(-1, 0, 0)
+	expiredToken := "expired-" + uuid.New().String()[:28]
+
+	if err := queries.UpdateUserKey(ctx, database.UpdateUserKeyParams{
+		UpdatedAt:       time.Now().Local(),
+		ApiKey:          expiredToken,
+		ApiKeyExpiresAt: expiredAt,
+		ID:              userID,
+	}); err != nil {
+		return fmt.Errorf("couldn't expire api key: %w", err)
+	}
+
+	return expireRefreshToken(ctx, queries, userID)
+}
+
+// expireRefreshToken replaces the user's refresh token with an expired one
+// that matches no token ever issued.
+func expireRefreshToken(ctx context.Context, queries *database.Queries, userID string) error {
+	expiredAt := time.Now().Local().AddDate(-1, 0, 0)
+
+	if err := queries.UpdateUserTK(ctx, database.UpdateUserTKParams{
+		UpdatedAt:             time.Now().Local(),
+		AccessTokenExpiresAt:  expiredAt,
+		RefreshToken:          "expired-" + uuid.New().String()[:28],
+		RefreshTokenExpiresAt: expiredAt,
+		UserID:                userID,
+	}); err != nil {
+		return fmt.Errorf("couldn't expire refresh token: %w", err)
+	}
+
+	return nil
+}
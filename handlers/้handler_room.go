@@ -2,9 +2,8 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"sort"
 	"time"
@@ -27,91 +26,86 @@ type BookedDate struct {
 	CheckOut time.Time `json:"check_out"`
 }
 
-func HandlerCreateRoom(cfg *config.ApiConfig) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		type parameters struct {
-			RoomName    string  `json:"room_name"`
-			Description *string `json:"description"`
-			Price       float64 `json:"price"`
-			MaxGuests   int32   `json:"max_guests"`
-		}
-
-		defer r.Body.Close()
-		decoder := json.NewDecoder(r.Body)
-		params := parameters{}
-		if err := decoder.Decode(&params); err != nil {
-			log.Println("Decode error: ", err)
-			return
-		}
+func HandlerCreateRoom(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	type parameters struct {
+		RoomName    string   `json:"room_name" validate:"required,max=100"`
+		Description *string  `json:"description" validate:"max=1000"`
+		Price       *float64 `json:"price" validate:"required,min=0"`
+		MaxGuests   int32    `json:"max_guests" validate:"required,min=1,max=50"`
+	}
 
-		description := sql.NullString{
-			String: "",
-			Valid:  false,
-		}
-		if params.Description != nil {
-			description.String = *params.Description
-			description.Valid = true
-		}
+	params := parameters{}
+	if err := middlewares.DecodeJSON(w, r, &params); err != nil {
+		return err
+	}
 
-		room_db, err := cfg.DB.CreateRoom(r.Context(), database.CreateRoomParams{
-			ID:          uuid.New().String(),
-			CreatedAt:   time.Now().Local(),
-			UpdatedAt:   time.Now().Local(),
-			RoomName:    params.RoomName,
-			Description: description,
-			Price:       fmt.Sprintf("%.2f", params.Price),
-			MaxGuests:   int32(params.MaxGuests),
-		})
-		if err != nil {
-			middlewares.RespondWithError(w, http.StatusInternalServerError, "Couldn't create room")
-			return
-		}
+	description := sql.NullString{
+		String: "",
+		Valid:  false,
+	}
+	if params.Description != nil {
+		description.String = *params.Description
+		description.Valid = true
+	}
 
-		middlewares.RespondWithJSON(w, http.StatusCreated, models.DBRoomToRoom(room_db))
+	room_db, err := cfg.DB.CreateRoom(r.Context(), database.CreateRoomParams{
+		ID:          uuid.New().String(),
+		CreatedAt:   time.Now().Local(),
+		UpdatedAt:   time.Now().Local(),
+		RoomName:    params.RoomName,
+		Description: description,
+		Price:       fmt.Sprintf("%.2f", *params.Price),
+		MaxGuests:   int32(params.MaxGuests),
+	})
+	if err != nil {
+		return middlewares.InternalError("Couldn't create room", fmt.Errorf("create room: %w", err))
 	}
-}
 
-func HandlerGetAllRooms(cfg *config.ApiConfig) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rooms, err := cfg.DB.GetAllRooms(r.Context())
-		if err != nil {
-			log.Println("Couldn't get all rooms error: ", err)
-			return
-		}
+	middlewares.RespondWithJSON(w, http.StatusCreated, models.DBRoomToRoom(room_db))
+	return nil
+}
 
-		middlewares.RespondWithJSON(w, http.StatusOK, rooms)
+func HandlerGetAllRooms(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request) error {
+	rooms, err := cfg.DB.GetAllRooms(r.Context())
+	if err != nil {
+		return middlewares.InternalError("Couldn't get rooms", fmt.Errorf("get all rooms: %w", err))
 	}
+
+	middlewares.RespondWithJSON(w, http.StatusOK, rooms)
+	return nil
 }
 
-func HandlerGetRoom(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+func HandlerGetRoom(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
 	roomID := chi.URLParam(r, "id")
 	if roomID == "" {
-		log.Println("Missing room id")
+		return middlewares.ValidationError("missing_parameter", "Missing room id")
 	}
 
 	room, err := cfg.DB.GetRoomByID(r.Context(), roomID)
 	if err != nil {
-		middlewares.RespondWithError(w, http.StatusNotFound, "Couldn't find room")
-		return
+		if errors.Is(err, sql.ErrNoRows) {
+			return middlewares.NotFoundError("room_not_found", "Couldn't find room")
+		}
+		return middlewares.InternalError("Couldn't get room", fmt.Errorf("get room: %w", err))
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusOK, room)
+	return nil
 }
 
-func HandlerGetRoomCalendar(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+func HandlerGetRoomCalendar(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
 	roomID := chi.URLParam(r, "room_id")
 	if roomID == "" {
-		log.Println("Missing room id")
+		return middlewares.ValidationError("missing_parameter", "Missing room id")
 	}
 
 	bookings, err := cfg.DB.GetBookedDatesByRoomID(r.Context(), roomID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			middlewares.RespondWithJSON(w, http.StatusOK, CalendarResponse{RoomID: roomID, BookedDates: []string{}})
-			return
+			return nil
 		}
-		log.Println("Failed to get booking error: ")
-		return
+		return middlewares.InternalError("Couldn't get room calendar", fmt.Errorf("get booked dates: %w", err))
 	}
 
 	var bookedDatesInput []BookedDate
@@ -129,6 +123,7 @@ func HandlerGetRoomCalendar(cfg *config.ApiConfig, w http.ResponseWriter, r *htt
 	}
 
 	middlewares.RespondWithJSON(w, http.StatusOK, response)
+	return nil
 }
 
 func generateBookedDates(bookings []BookedDate) []string {
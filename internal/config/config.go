@@ -0,0 +1,410 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/STaninnat/booking-backend/internal/oidc"
+	"github.com/STaninnat/booking-backend/internal/tracing"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/go-chi/cors"
+	"github.com/joho/godotenv"
+	"github.com/lib/pq"
+)
+
+// minSecretLength is the shortest HMAC secret accepted, matching the 32
+// random bytes `openssl rand -base64 32` produces.
+const minSecretLength = 32
+
+// Environments accepted in APP_ENV.
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// envFiles are loaded from the working directory when present. Earlier
+// files win over later ones.
+var envFiles = []string{".env.development", ".env"}
+
+// Secret is a configuration value that is never printed.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// Config is the server configuration, loaded and validated once at startup.
+type Config struct {
+	Environment string
+	Port        string
+	DatabaseURL Secret
+	AutoMigrate bool
+	FrontendURL string
+	MetricsAddr string
+
+	JWTSecret        Secret
+	RefreshSecret    Secret
+	JWTKeysDir       string
+	JWTActiveKeyID   string
+	JWTRetiredKeyIDs []string
+	Token            security.TokenSettings
+
+	Mailer               string
+	MailerDir            string
+	RequireVerifiedEmail bool
+	TOTPIssuer           string
+
+	PasswordPolicy        *security.PasswordPolicy
+	PasswordBlocklistFile string
+	PasswordHasher        *security.PasswordHasher
+
+	OIDC           []oidc.Config
+	CORS           cors.Options
+	TrustedOrigins []string
+
+	Log        LogSettings
+	RateLimits RateLimits
+	Tracing    tracing.Settings
+	Shutdown   ShutdownSettings
+	DBPool     DBPoolSettings
+}
+
+// Load reads the configuration. In order of precedence, values come from
+// the environment, the .env files, the config file named by configFile (or
+// CONFIG_FILE) and finally the defaults. Every invalid or insecure value is
+// reported in the returned error, not just the first.
+func Load(configFile string) (*Config, error) {
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if err := loadFiles(configFile); err != nil {
+		return nil, err
+	}
+
+	return fromEnv()
+}
+
+// loadFiles copies values from the .env files and the config file into the
+// environment without overriding anything already set, so the other
+// loaders see them too.
+func loadFiles(configFile string) error {
+	for _, name := range envFiles {
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := godotenv.Load(name); err != nil {
+			return fmt.Errorf("load %s: %w", name, err)
+		}
+	}
+
+	if configFile != "" {
+		if err := godotenv.Load(configFile); err != nil {
+			return fmt.Errorf("load config file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LoadMigrate reads only what `booking migrate` needs: DATABASE_URL, which
+// is required, and the pool settings. Files are loaded as in Load, but the
+// server's own settings are neither read nor validated, so migrations can
+// run from a job that has no secrets configured.
+func LoadMigrate(configFile string) (*Config, error) {
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if err := loadFiles(configFile); err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{DatabaseURL: Secret(os.Getenv("DATABASE_URL"))}
+
+	var errs []error
+	if cfg.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required to migrate"))
+	} else if err := validateDatabaseURL(cfg.DatabaseURL); err != nil {
+		errs = append(errs, err)
+	}
+
+	var err error
+	if cfg.DBPool, err = LoadDBPoolSettings(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func fromEnv() (*Config, error) {
+	cfg := &Config{
+		Environment:      os.Getenv("APP_ENV"),
+		Port:             os.Getenv("PORT"),
+		DatabaseURL:      Secret(os.Getenv("DATABASE_URL")),
+		FrontendURL:      strings.TrimSuffix(os.Getenv("FRONTEND_URL"), "/"),
+		MetricsAddr:      os.Getenv("METRICS_ADDR"),
+		JWTSecret:        Secret(os.Getenv("JWT_SECRET")),
+		RefreshSecret:    Secret(os.Getenv("REFRESH_SECRET")),
+		JWTKeysDir:       os.Getenv("JWT_KEYS_DIR"),
+		JWTActiveKeyID:   os.Getenv("JWT_ACTIVE_KEY_ID"),
+		JWTRetiredKeyIDs: listFromEnv("JWT_RETIRED_KEY_IDS", nil),
+		Mailer:           os.Getenv("MAILER"),
+		MailerDir:        os.Getenv("MAILER_DIR"),
+		TOTPIssuer:       os.Getenv("TOTP_ISSUER"),
+
+		PasswordBlocklistFile: os.Getenv("PASSWORD_BLOCKLIST_FILE"),
+	}
+	if cfg.Environment == "" {
+		cfg.Environment = EnvProduction
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	// Only development falls back to a mailer that delivers nothing.
+	if cfg.Mailer == "" && cfg.Environment == EnvDevelopment {
+		cfg.Mailer = "log"
+	}
+	if cfg.TOTPIssuer == "" {
+		cfg.TOTPIssuer = "Booking"
+	}
+
+	var errs []error
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var err error
+	cfg.AutoMigrate, err = boolFromEnv("AUTO_MIGRATE", false)
+	collect(err)
+	cfg.RequireVerifiedEmail, err = boolFromEnv("REQUIRE_VERIFIED_EMAIL", false)
+	collect(err)
+	cfg.Token, err = LoadTokenSettings()
+	collect(err)
+	cfg.Log, err = LoadLogSettings()
+	collect(err)
+	cfg.RateLimits, err = LoadRateLimits()
+	collect(err)
+	cfg.Tracing, err = LoadTracingSettings()
+	collect(err)
+	cfg.Shutdown, err = LoadShutdownSettings()
+	collect(err)
+	cfg.DBPool, err = LoadDBPoolSettings()
+	collect(err)
+	if cfg.PasswordPolicy, err = LoadPasswordPolicy(); err != nil {
+		collect(fmt.Errorf("password policy: %w", err))
+	}
+	if cfg.PasswordHasher, err = LoadPasswordHasher(); err != nil {
+		collect(fmt.Errorf("password hashing: %w", err))
+	}
+	cfg.OIDC, err = LoadOIDCConfigs()
+	collect(err)
+	cfg.CORS, err = LoadCORSOptions(cfg.FrontendURL)
+	collect(err)
+	cfg.TrustedOrigins, err = LoadTrustedOrigins(cfg.FrontendURL)
+	collect(err)
+
+	collect(cfg.validate())
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate rejects values the server would run with but shouldn't: missing
+// or short secrets in particular, which used to only log a warning.
+func (c *Config) validate() error {
+	var errs []error
+
+	if c.Environment != EnvDevelopment && c.Environment != EnvProduction {
+		errs = append(errs, fmt.Errorf("APP_ENV: %q must be %s or %s", c.Environment, EnvDevelopment, EnvProduction))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q is not a valid port", c.Port))
+	}
+
+	switch {
+	case c.JWTSecret == "" && c.JWTKeysDir == "":
+		errs = append(errs, errors.New("JWT_SECRET or JWT_KEYS_DIR is required"))
+	case c.JWTSecret != "" && len(c.JWTSecret) < minSecretLength:
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters", minSecretLength))
+	}
+	switch {
+	case c.RefreshSecret == "":
+		errs = append(errs, errors.New("REFRESH_SECRET is required"))
+	case len(c.RefreshSecret) < minSecretLength:
+		errs = append(errs, fmt.Errorf("REFRESH_SECRET must be at least %d characters", minSecretLength))
+	case c.RefreshSecret == c.JWTSecret:
+		errs = append(errs, errors.New("REFRESH_SECRET must differ from JWT_SECRET"))
+	}
+
+	if u, err := url.Parse(c.FrontendURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, errors.New("FRONTEND_URL must be an absolute http or https URL"))
+	}
+
+	if c.Mailer == "" {
+		errs = append(errs, errors.New("MAILER is required outside development"))
+	}
+
+	if c.DatabaseURL != "" {
+		if err := validateDatabaseURL(c.DatabaseURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateDatabaseURL parses the value with the driver, which accepts both
+// postgres:// URLs and key=value connection strings, without connecting.
+// The parse error isn't returned because it may quote the password.
+func validateDatabaseURL(s Secret) error {
+	if _, err := pq.NewConnector(string(s)); err != nil {
+		return errors.New("DATABASE_URL must be a postgres:// URL or a key=value connection string")
+	}
+	return nil
+}
+
+// Warnings lists settings that are valid but unsafe for the environment.
+// They are logged at startup.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.Environment != EnvDevelopment && (c.Mailer == "log" || c.Mailer == "file") {
+		warnings = append(warnings, fmt.Sprintf("MAILER=%s delivers no email: password reset and verification messages will not reach users", c.Mailer))
+	}
+	if c.Environment != EnvDevelopment && c.MetricsAddr == "" {
+		warnings = append(warnings, "METRICS_ADDR is not set: /metrics is served without authentication on the public port")
+	}
+	return warnings
+}
+
+type printedLine struct {
+	key   string
+	value any
+}
+
+// Print writes the configuration as KEY=value lines, the format Load reads,
+// with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	lines := []printedLine{
+		{"APP_ENV", c.Environment},
+		{"PORT", c.Port},
+		{"DATABASE_URL", redactURL(c.DatabaseURL)},
+		{"AUTO_MIGRATE", c.AutoMigrate},
+		{"FRONTEND_URL", c.FrontendURL},
+		{"METRICS_ADDR", c.MetricsAddr},
+		{"JWT_SECRET", c.JWTSecret},
+		{"REFRESH_SECRET", c.RefreshSecret},
+		{"JWT_KEYS_DIR", c.JWTKeysDir},
+		{"JWT_ACTIVE_KEY_ID", c.JWTActiveKeyID},
+		{"JWT_RETIRED_KEY_IDS", strings.Join(c.JWTRetiredKeyIDs, ",")},
+		{"API_SERVICE_NAME", c.Token.Issuer},
+		{"FRONTEND_APP_NAME", c.Token.Audience},
+		{"ACCESS_TOKEN_TTL", c.Token.AccessTokenTTL},
+		{"REFRESH_TOKEN_TTL", c.Token.RefreshTokenTTL},
+		{"JWT_LEEWAY", c.Token.Leeway},
+		{"MAILER", c.Mailer},
+		{"MAILER_DIR", c.MailerDir},
+		{"REQUIRE_VERIFIED_EMAIL", c.RequireVerifiedEmail},
+		{"TOTP_ISSUER", c.TOTPIssuer},
+		{"LOG_FORMAT", c.Log.Format},
+		{"LOG_LEVEL", c.Log.Level},
+		{"RATE_LIMIT_DEFAULT", c.RateLimits.Default},
+		{"RATE_LIMIT_AUTH", c.RateLimits.Auth},
+		{"RATE_LIMIT_BOOKINGS", c.RateLimits.Bookings},
+		{"TRACING_EXPORTER", c.Tracing.Exporter},
+		{"TRACING_FILE", c.Tracing.FilePath},
+		{"TRACING_SAMPLE_RATIO", c.Tracing.SampleRatio},
+		{"SHUTDOWN_DRAIN_DELAY", c.Shutdown.DrainDelay},
+		{"SHUTDOWN_TIMEOUT", c.Shutdown.Timeout},
+		{"DB_MAX_OPEN_CONNS", c.DBPool.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", c.DBPool.MaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", c.DBPool.ConnMaxLifetime},
+		{"PASSWORD_MIN_LENGTH", c.PasswordPolicy.MinLength},
+		{"PASSWORD_MAX_LENGTH", c.PasswordPolicy.MaxLength},
+		{"PASSWORD_BLOCKLIST_FILE", c.PasswordBlocklistFile},
+		{"PASSWORD_HASH_ALGORITHM", c.PasswordHasher.Algorithm},
+		{"PASSWORD_BCRYPT_COST", c.PasswordHasher.BcryptCost},
+		{"PASSWORD_ARGON2_MEMORY_KIB", c.PasswordHasher.Argon2.Memory},
+		{"PASSWORD_ARGON2_ITERATIONS", c.PasswordHasher.Argon2.Iterations},
+		{"PASSWORD_ARGON2_PARALLELISM", c.PasswordHasher.Argon2.Parallelism},
+		{"CORS_ALLOWED_ORIGINS", strings.Join(c.CORS.AllowedOrigins, ",")},
+		{"CORS_ALLOWED_METHODS", strings.Join(c.CORS.AllowedMethods, ",")},
+		{"CORS_ALLOWED_HEADERS", strings.Join(c.CORS.AllowedHeaders, ",")},
+		{"CORS_EXPOSED_HEADERS", strings.Join(c.CORS.ExposedHeaders, ",")},
+		{"CORS_ALLOW_CREDENTIALS", c.CORS.AllowCredentials},
+		{"CORS_MAX_AGE", c.CORS.MaxAge},
+		{"CSRF_TRUSTED_ORIGINS", strings.Join(c.TrustedOrigins, ",")},
+	}
+
+	names := make([]string, 0, len(c.OIDC))
+	for _, provider := range c.OIDC {
+		names = append(names, provider.Name)
+	}
+	lines = append(lines, printedLine{"OIDC_PROVIDERS", strings.Join(names, ",")})
+	for _, provider := range c.OIDC {
+		prefix := "OIDC_" + strings.ToUpper(provider.Name) + "_"
+		lines = append(lines, []printedLine{
+			{prefix + "ISSUER", provider.Issuer},
+			{prefix + "CLIENT_ID", provider.ClientID},
+			{prefix + "CLIENT_SECRET", Secret(provider.ClientSecret)},
+			{prefix + "REDIRECT_URL", provider.RedirectURL},
+			{prefix + "SCOPES", strings.Join(provider.Scopes, " ")},
+		}...)
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(w, "%s=%q\n", line.key, fmt.Sprint(line.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func boolFromEnv(name string, fallback bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return b, nil
+}
+
+// dsnPassword matches the password in a key=value connection string,
+// quoted or not.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactURL keeps a connection URL or key=value connection string readable
+// but hides its password.
+func redactURL(s Secret) string {
+	if s == "" {
+		return ""
+	}
+	if !strings.Contains(string(s), "://") {
+		return dsnPassword.ReplaceAllString(string(s), "${1}xxxxx")
+	}
+	u, err := url.Parse(string(s))
+	if err != nil {
+		return s.String()
+	}
+	return u.Redacted()
+}
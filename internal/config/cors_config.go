@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/go-chi/cors"
+)
+
+const maxCORSMaxAge = 86400
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}
+	defaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type"}
+
+	allowedCORSMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	headerNameRegex = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+)
+
+// LoadCORSOptions builds the CORS policy from CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS,
+// CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE. Origins default to the
+// frontend's own origin, and wildcards are refused while credentials are
+// allowed so cookies are only ever sent from origins listed explicitly.
+func LoadCORSOptions(frontendURL string) (cors.Options, error) {
+	allowCredentials := true
+	if value := os.Getenv("CORS_ALLOW_CREDENTIALS"); value != "" {
+		var err error
+		allowCredentials, err = strconv.ParseBool(value)
+		if err != nil {
+			return cors.Options{}, fmt.Errorf("CORS_ALLOW_CREDENTIALS: %w", err)
+		}
+	}
+
+	origins, err := corsOrigins(frontendURL, allowCredentials)
+	if err != nil {
+		return cors.Options{}, err
+	}
+
+	methods := listFromEnv("CORS_ALLOWED_METHODS", defaultCORSMethods)
+	for i, method := range methods {
+		methods[i] = strings.ToUpper(method)
+		if !slices.Contains(allowedCORSMethods, methods[i]) {
+			return cors.Options{}, fmt.Errorf("CORS_ALLOWED_METHODS: unsupported method %q", method)
+		}
+	}
+
+	headers := listFromEnv("CORS_ALLOWED_HEADERS", defaultCORSHeaders)
+	if err := validateHeaderNames("CORS_ALLOWED_HEADERS", headers); err != nil {
+		return cors.Options{}, err
+	}
+
+	exposedHeaders := listFromEnv("CORS_EXPOSED_HEADERS", []string{"Link", "X-Request-ID"})
+	if err := validateHeaderNames("CORS_EXPOSED_HEADERS", exposedHeaders); err != nil {
+		return cors.Options{}, err
+	}
+
+	maxAge, err := intFromEnv("CORS_MAX_AGE", 300)
+	if err != nil {
+		return cors.Options{}, err
+	}
+	if maxAge < 0 || maxAge > maxCORSMaxAge {
+		return cors.Options{}, fmt.Errorf("CORS_MAX_AGE must be between 0 and %d seconds", maxCORSMaxAge)
+	}
+
+	return cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   methods,
+		AllowedHeaders:   headers,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: allowCredentials,
+		MaxAge:           maxAge,
+	}, nil
+}
+
+func corsOrigins(frontendURL string, allowCredentials bool) ([]string, error) {
+	raw := listFromEnv("CORS_ALLOWED_ORIGINS", nil)
+	if len(raw) == 0 {
+		origin := security.NormalizeOrigin(frontendURL)
+		if origin == "" {
+			return nil, errors.New("CORS_ALLOWED_ORIGINS or a valid FRONTEND_URL is required")
+		}
+		return []string{origin}, nil
+	}
+
+	origins := make([]string, 0, len(raw))
+	for _, value := range raw {
+		if strings.Contains(value, "*") {
+			if allowCredentials {
+				return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS: wildcard %q isn't allowed while CORS_ALLOW_CREDENTIALS is true", value)
+			}
+			origins = append(origins, strings.ToLower(value))
+			continue
+		}
+
+		origin := security.NormalizeOrigin(value)
+		if origin == "" || origin != strings.ToLower(strings.TrimSuffix(value, "/")) {
+			return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS: invalid origin %q, expected scheme://host[:port]", value)
+		}
+		origins = append(origins, origin)
+	}
+
+	return origins, nil
+}
+
+func validateHeaderNames(name string, headers []string) error {
+	for _, header := range headers {
+		if header == "*" {
+			return fmt.Errorf("%s: list headers explicitly instead of %q", name, header)
+		}
+		if !headerNameRegex.MatchString(header) {
+			return fmt.Errorf("%s: invalid header name %q", name, header)
+		}
+	}
+	return nil
+}
+
+// listFromEnv splits a comma separated variable, dropping empty entries.
+// The fallback is copied so callers may modify the result.
+func listFromEnv(name string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return slices.Clone(fallback)
+	}
+	return values
+}
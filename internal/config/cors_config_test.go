@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCORSOptionsDefaults(t *testing.T) {
+	opts, err := LoadCORSOptions("https://app.example.com/")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://app.example.com"}, opts.AllowedOrigins)
+	assert.True(t, opts.AllowCredentials)
+	assert.NotContains(t, opts.AllowedHeaders, "*")
+	assert.Equal(t, 300, opts.MaxAge)
+}
+
+func TestLoadCORSOptionsFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://admin.example.com")
+	t.Setenv("CORS_ALLOWED_METHODS", "get,post")
+	t.Setenv("CORS_ALLOWED_HEADERS", "Content-Type,X-Request-ID")
+	t.Setenv("CORS_MAX_AGE", "600")
+
+	opts, err := LoadCORSOptions("")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, opts.AllowedOrigins)
+	assert.Equal(t, []string{"GET", "POST"}, opts.AllowedMethods)
+	assert.Equal(t, []string{"Content-Type", "X-Request-ID"}, opts.AllowedHeaders)
+	assert.Equal(t, 600, opts.MaxAge)
+}
+
+func TestLoadCORSOptionsRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"no origin configured", map[string]string{}},
+		{"wildcard with credentials", map[string]string{"CORS_ALLOWED_ORIGINS": "https://*"}},
+		{"origin with path", map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com/login"}},
+		{"origin without scheme", map[string]string{"CORS_ALLOWED_ORIGINS": "app.example.com"}},
+		{"unknown method", map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com", "CORS_ALLOWED_METHODS": "FETCH"}},
+		{"wildcard header", map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com", "CORS_ALLOWED_HEADERS": "*"}},
+		{"negative max age", map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com", "CORS_MAX_AGE": "-1"}},
+		{"bad credentials flag", map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com", "CORS_ALLOW_CREDENTIALS": "maybe"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := LoadCORSOptions("")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadCORSOptionsAllowsWildcardWithoutCredentials(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://*.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+
+	opts, err := LoadCORSOptions("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://*.example.com"}, opts.AllowedOrigins)
+	assert.False(t, opts.AllowCredentials)
+}
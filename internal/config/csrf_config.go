@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/STaninnat/booking-backend/security"
+)
+
+// LoadTrustedOrigins reads the origins allowed to make cookie-authenticated,
+// state-changing requests from CSRF_TRUSTED_ORIGINS (comma separated). The
+// frontend's own origin is always trusted.
+func LoadTrustedOrigins(frontendURL string) ([]string, error) {
+	var origins []string
+	if origin := security.NormalizeOrigin(frontendURL); origin != "" {
+		origins = append(origins, origin)
+	}
+
+	for _, raw := range listFromEnv("CSRF_TRUSTED_ORIGINS", nil) {
+		origin := security.NormalizeOrigin(raw)
+		if origin == "" || strings.Contains(origin, "*") {
+			return nil, fmt.Errorf("CSRF_TRUSTED_ORIGINS: invalid origin %q, expected scheme://host[:port]", raw)
+		}
+		origins = append(origins, origin)
+	}
+
+	return origins, nil
+}
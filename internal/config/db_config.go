@@ -2,13 +2,38 @@ package config
 
 import (
 	"database/sql"
+	"sync"
 
 	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/health"
+	"github.com/STaninnat/booking-backend/internal/mailer"
+	"github.com/STaninnat/booking-backend/internal/metrics"
+	"github.com/STaninnat/booking-backend/internal/oidc"
+	"github.com/STaninnat/booking-backend/security"
 )
 
 type ApiConfig struct {
-	DB            *database.Queries
-	DBConn        *sql.DB
-	JWTSecret     string
-	RefreshSecret string
+	DB          *database.Queries
+	DBConn      *sql.DB
+	JWTKeys     *security.KeyRing
+	RefreshKeys *security.KeyRing
+	Token       security.TokenSettings
+	Mailer      mailer.Mailer
+	FrontendURL string
+	TOTPIssuer  string
+
+	OIDCProviders map[string]*oidc.Provider
+
+	LoginThrottle  *security.LoginThrottle
+	PasswordPolicy *security.PasswordPolicy
+	PasswordHasher *security.PasswordHasher
+
+	RequireVerifiedEmail bool
+
+	Metrics   *metrics.Metrics
+	Readiness *health.Readiness
+
+	// Background tracks work handlers run after responding, so shutdown
+	// can wait for it.
+	Background *sync.WaitGroup
 }
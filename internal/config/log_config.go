@@ -0,0 +1,42 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/STaninnat/booking-backend/internal/logging"
+)
+
+type LogSettings struct {
+	Format string
+	Level  string
+}
+
+// LoadLogSettings reads LOG_FORMAT ("text" by default, "json" in
+// production) and LOG_LEVEL ("info" by default).
+func LoadLogSettings() (LogSettings, error) {
+	settings := LogSettings{
+		Format: os.Getenv("LOG_FORMAT"),
+		Level:  os.Getenv("LOG_LEVEL"),
+	}
+	if settings.Format == "" {
+		settings.Format = "text"
+	}
+	if settings.Level == "" {
+		settings.Level = "info"
+	}
+
+	// Build a throwaway logger so bad values fail with the rest of the
+	// configuration.
+	if _, err := logging.New(io.Discard, settings.Format, settings.Level); err != nil {
+		return LogSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// NewLogger returns the logger writing to stdout.
+func (s LogSettings) NewLogger() (*slog.Logger, error) {
+	return logging.New(os.Stdout, s.Format, s.Level)
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/STaninnat/booking-backend/internal/oidc"
+)
+
+var oidcProviderNameRegex = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// LoadOIDCConfigs reads the providers named in OIDC_PROVIDERS (comma
+// separated), in order. Each name NAME is configured through
+// OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET,
+// OIDC_NAME_REDIRECT_URL and the optional OIDC_NAME_SCOPES.
+func LoadOIDCConfigs() ([]oidc.Config, error) {
+	var configs []oidc.Config
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !oidcProviderNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q", name)
+		}
+		if slices.ContainsFunc(configs, func(c oidc.Config) bool { return c.Name == name }) {
+			return nil, fmt.Errorf("duplicate OIDC provider %q", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providerConfig := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if providerConfig.Issuer == "" || providerConfig.ClientID == "" || providerConfig.RedirectURL == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+
+		configs = append(configs, providerConfig)
+	}
+
+	return configs, nil
+}
+
+// NewOIDCProviders creates a provider for each loaded configuration, keyed
+// by name.
+func NewOIDCProviders(configs []oidc.Config) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(configs))
+	for _, providerConfig := range configs {
+		providers[providerConfig.Name] = oidc.NewProvider(providerConfig, nil)
+	}
+	return providers
+}
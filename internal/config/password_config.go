@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/STaninnat/booking-backend/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultPasswordMinLength = 8
+
+// LoadPasswordPolicy builds the policy shared by signup, password reset and
+// password change from PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH and the
+// optional PASSWORD_BLOCKLIST_FILE.
+func LoadPasswordPolicy() (*security.PasswordPolicy, error) {
+	minLength, err := intFromEnv("PASSWORD_MIN_LENGTH", defaultPasswordMinLength)
+	if err != nil {
+		return nil, err
+	}
+
+	maxLength, err := intFromEnv("PASSWORD_MAX_LENGTH", security.BcryptMaxPasswordBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked []string
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		blocked, err = security.LoadPasswordBlocklist(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return security.NewPasswordPolicy(minLength, maxLength, blocked)
+}
+
+// LoadPasswordHasher configures how new passwords are hashed. Existing
+// hashes made with other settings keep working and are upgraded at sign-in.
+func LoadPasswordHasher() (*security.PasswordHasher, error) {
+	algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM")
+	if algorithm == "" {
+		algorithm = security.HashAlgorithmBcrypt
+	}
+
+	bcryptCost, err := intFromEnv("PASSWORD_BCRYPT_COST", bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	params := security.DefaultArgon2Params
+	memory, err := intFromEnv("PASSWORD_ARGON2_MEMORY_KIB", int(params.Memory))
+	if err != nil {
+		return nil, err
+	}
+	iterations, err := intFromEnv("PASSWORD_ARGON2_ITERATIONS", int(params.Iterations))
+	if err != nil {
+		return nil, err
+	}
+	parallelism, err := intFromEnv("PASSWORD_ARGON2_PARALLELISM", int(params.Parallelism))
+	if err != nil {
+		return nil, err
+	}
+	if memory < 0 || iterations < 0 || parallelism < 0 || parallelism > 255 {
+		return nil, errors.New("argon2id parameters out of range")
+	}
+	params.Memory = uint32(memory)          // #nosec G115 -- range checked above
+	params.Iterations = uint32(iterations)  // #nosec G115 -- range checked above
+	params.Parallelism = uint8(parallelism) // #nosec G115 -- range checked above
+
+	return security.NewPasswordHasher(algorithm, bcryptCost, params)
+}
+
+func intFromEnv(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return n, nil
+}
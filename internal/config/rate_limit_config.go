@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitSettings is a token bucket: Limit requests, refilled evenly over
+// Period.
+type RateLimitSettings struct {
+	Limit  int
+	Period time.Duration
+}
+
+// String formats s the way RATE_LIMIT_* values are written.
+func (s RateLimitSettings) String() string {
+	return strconv.Itoa(s.Limit) + "/" + s.Period.String()
+}
+
+// RateLimits holds the policy of each route group.
+type RateLimits struct {
+	Default  RateLimitSettings
+	Auth     RateLimitSettings
+	Bookings RateLimitSettings
+}
+
+// LoadRateLimits reads RATE_LIMIT_DEFAULT, RATE_LIMIT_AUTH and
+// RATE_LIMIT_BOOKINGS, each written as "<requests>/<duration>", e.g. "10/1m".
+func LoadRateLimits() (RateLimits, error) {
+	var limits RateLimits
+	var err error
+
+	if limits.Default, err = rateLimitFromEnv("RATE_LIMIT_DEFAULT", RateLimitSettings{Limit: 300, Period: time.Minute}); err != nil {
+		return RateLimits{}, err
+	}
+	if limits.Auth, err = rateLimitFromEnv("RATE_LIMIT_AUTH", RateLimitSettings{Limit: 10, Period: time.Minute}); err != nil {
+		return RateLimits{}, err
+	}
+	if limits.Bookings, err = rateLimitFromEnv("RATE_LIMIT_BOOKINGS", RateLimitSettings{Limit: 30, Period: time.Minute}); err != nil {
+		return RateLimits{}, err
+	}
+
+	return limits, nil
+}
+
+func rateLimitFromEnv(name string, fallback RateLimitSettings) (RateLimitSettings, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	rawLimit, rawPeriod, found := strings.Cut(value, "/")
+	if !found {
+		return RateLimitSettings{}, fmt.Errorf("%s: expected <requests>/<duration>, got %q", name, value)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(rawLimit))
+	if err != nil || limit <= 0 {
+		return RateLimitSettings{}, fmt.Errorf("%s: request count must be a positive integer", name)
+	}
+
+	period, err := time.ParseDuration(strings.TrimSpace(rawPeriod))
+	if err != nil || period < time.Second {
+		return RateLimitSettings{}, fmt.Errorf("%s: period must be a duration of at least 1s", name)
+	}
+
+	return RateLimitSettings{Limit: limit, Period: period}, nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT_AUTH", "5/30s")
+
+	limits, err := LoadRateLimits()
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitSettings{Limit: 5, Period: 30 * time.Second}, limits.Auth)
+	assert.Equal(t, RateLimitSettings{Limit: 300, Period: time.Minute}, limits.Default)
+
+	for _, value := range []string{"10", "0/1m", "ten/1m", "10/soon", "10/100ms"} {
+		t.Setenv("RATE_LIMIT_BOOKINGS", value)
+		_, err := LoadRateLimits()
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 15 * time.Second
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+)
+
+// ShutdownSettings control how the server stops. DrainDelay is how long
+// /readyz fails before the listener closes, giving load balancers time to
+// stop routing; Timeout bounds the wait for in-flight requests after that.
+type ShutdownSettings struct {
+	DrainDelay time.Duration
+	Timeout    time.Duration
+}
+
+// LoadShutdownSettings reads SHUTDOWN_DRAIN_DELAY (none by default) and
+// SHUTDOWN_TIMEOUT (15s by default).
+func LoadShutdownSettings() (ShutdownSettings, error) {
+	var settings ShutdownSettings
+
+	if value := os.Getenv("SHUTDOWN_DRAIN_DELAY"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return ShutdownSettings{}, fmt.Errorf("SHUTDOWN_DRAIN_DELAY: %w", err)
+		}
+		if d < 0 {
+			return ShutdownSettings{}, errors.New("SHUTDOWN_DRAIN_DELAY must not be negative")
+		}
+		settings.DrainDelay = d
+	}
+
+	var err error
+	if settings.Timeout, err = durationFromEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout); err != nil {
+		return ShutdownSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// DBPoolSettings are the database/sql connection pool limits.
+type DBPoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// LoadDBPoolSettings reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and
+// DB_CONN_MAX_LIFETIME.
+func LoadDBPoolSettings() (DBPoolSettings, error) {
+	var (
+		settings DBPoolSettings
+		err      error
+	)
+	if settings.MaxOpenConns, err = intFromEnv("DB_MAX_OPEN_CONNS", defaultMaxOpenConns); err != nil {
+		return DBPoolSettings{}, err
+	}
+	if settings.MaxIdleConns, err = intFromEnv("DB_MAX_IDLE_CONNS", defaultMaxIdleConns); err != nil {
+		return DBPoolSettings{}, err
+	}
+	if settings.ConnMaxLifetime, err = durationFromEnv("DB_CONN_MAX_LIFETIME", defaultConnMaxLifetime); err != nil {
+		return DBPoolSettings{}, err
+	}
+
+	if settings.MaxOpenConns < 1 {
+		return DBPoolSettings{}, errors.New("DB_MAX_OPEN_CONNS must be at least 1")
+	}
+	if settings.MaxIdleConns < 0 || settings.MaxIdleConns > settings.MaxOpenConns {
+		return DBPoolSettings{}, errors.New("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS")
+	}
+
+	return settings, nil
+}
+
+// Apply sets the pool limits on db.
+func (s DBPoolSettings) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+	db.SetConnMaxLifetime(s.ConnMaxLifetime)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadShutdownSettings(t *testing.T) {
+	settings, err := LoadShutdownSettings()
+	require.NoError(t, err)
+	assert.Equal(t, ShutdownSettings{Timeout: 15 * time.Second}, settings)
+
+	t.Setenv("SHUTDOWN_DRAIN_DELAY", "5s")
+	t.Setenv("SHUTDOWN_TIMEOUT", "30s")
+	settings, err = LoadShutdownSettings()
+	require.NoError(t, err)
+	assert.Equal(t, ShutdownSettings{DrainDelay: 5 * time.Second, Timeout: 30 * time.Second}, settings)
+
+	t.Setenv("SHUTDOWN_DRAIN_DELAY", "-1s")
+	_, err = LoadShutdownSettings()
+	assert.Error(t, err)
+}
+
+func TestLoadDBPoolSettings(t *testing.T) {
+	settings, err := LoadDBPoolSettings()
+	require.NoError(t, err)
+	assert.Equal(t, DBPoolSettings{MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetime: 30 * time.Minute}, settings)
+
+	for name, value := range map[string]string{
+		"DB_MAX_OPEN_CONNS":    "0",
+		"DB_MAX_IDLE_CONNS":    "30",
+		"DB_CONN_MAX_LIFETIME": "forever",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := LoadDBPoolSettings()
+			assert.Error(t, err)
+		})
+	}
+}
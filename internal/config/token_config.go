@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/STaninnat/booking-backend/security"
+)
+
+const (
+	defaultAccessTokenTTL  = 1 * time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultJWTLeeway       = 30 * time.Second
+)
+
+// LoadTokenSettings reads the JWT issuer, audience, lifetimes and clock-skew
+// leeway once at startup. A missing issuer or audience is an error because
+// every token would otherwise be rejected at validation.
+func LoadTokenSettings() (security.TokenSettings, error) {
+	settings := security.TokenSettings{
+		Issuer:   os.Getenv("API_SERVICE_NAME"),
+		Audience: os.Getenv("FRONTEND_APP_NAME"),
+	}
+
+	if settings.Issuer == "" {
+		return security.TokenSettings{}, errors.New("API_SERVICE_NAME environment variable is not set")
+	}
+	if settings.Audience == "" {
+		return security.TokenSettings{}, errors.New("FRONTEND_APP_NAME environment variable is not set")
+	}
+
+	var err error
+	if settings.AccessTokenTTL, err = durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL); err != nil {
+		return security.TokenSettings{}, err
+	}
+	if settings.RefreshTokenTTL, err = durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL); err != nil {
+		return security.TokenSettings{}, err
+	}
+	if settings.Leeway, err = durationFromEnv("JWT_LEEWAY", defaultJWTLeeway); err != nil {
+		return security.TokenSettings{}, err
+	}
+
+	if settings.AccessTokenTTL >= settings.RefreshTokenTTL {
+		return security.TokenSettings{}, errors.New("ACCESS_TOKEN_TTL must be shorter than REFRESH_TOKEN_TTL")
+	}
+
+	return settings, nil
+}
+
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+
+	return d, nil
+}
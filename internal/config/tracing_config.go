@@ -0,0 +1,40 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/STaninnat/booking-backend/internal/tracing"
+)
+
+// LoadTracingSettings reads TRACING_EXPORTER ("none" by default), the
+// TRACING_FILE used by the file exporter and TRACING_SAMPLE_RATIO (1 by
+// default, meaning every trace).
+func LoadTracingSettings() (tracing.Settings, error) {
+	settings := tracing.Settings{
+		Exporter:    os.Getenv("TRACING_EXPORTER"),
+		FilePath:    os.Getenv("TRACING_FILE"),
+		SampleRatio: 1,
+	}
+	if settings.Exporter == "" {
+		settings.Exporter = tracing.ExporterNone
+	}
+	if settings.FilePath == "" {
+		settings.FilePath = "traces.jsonl"
+	}
+
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return tracing.Settings{}, fmt.Errorf("TRACING_SAMPLE_RATIO: %w", err)
+		}
+		if ratio < 0 || ratio > 1 {
+			return tracing.Settings{}, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1")
+		}
+		settings.SampleRatio = ratio
+	}
+
+	return settings, nil
+}
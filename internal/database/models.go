@@ -19,6 +19,29 @@ type Booking struct {
 	RoomID    string
 }
 
+type PasswordReset struct {
+	ID        string
+	CreatedAt time.Time
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	UserID    string
+}
+
+type RecoveryCode struct {
+	ID        string
+	CreatedAt time.Time
+	CodeHash  string
+	UsedAt    sql.NullTime
+	UserID    string
+}
+
+type RolePolicy struct {
+	Role        string
+	UpdatedAt   time.Time
+	RequireTotp bool
+}
+
 type Room struct {
 	ID          string
 	CreatedAt   time.Time
@@ -30,16 +53,35 @@ type Room struct {
 }
 
 type User struct {
-	ID              string
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
-	FullName        string
-	Email           string
-	Phone           sql.NullString
-	Username        string
-	Password        string
-	ApiKey          string
-	ApiKeyExpiresAt time.Time
+	ID                      string
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	FullName                string
+	Email                   string
+	Phone                   sql.NullString
+	Username                string
+	Password                string
+	ApiKey                  string
+	ApiKeyExpiresAt         time.Time
+	EmailVerifiedAt         sql.NullTime
+	EmailVerificationSentAt sql.NullTime
+	TokensInvalidBefore     sql.NullTime
+	Role                    string
+	FailedLoginAttempts     int32
+	LockedUntil             sql.NullTime
+	TotpSecret              sql.NullString
+	TotpEnabledAt           sql.NullTime
+	TotpLastCounter         int64
+	DeletedAt               sql.NullTime
+}
+
+type UserIdentity struct {
+	ID        string
+	CreatedAt time.Time
+	Provider  string
+	Subject   string
+	Email     sql.NullString
+	UserID    string
 }
 
 type UsersToken struct {
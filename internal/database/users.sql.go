@@ -11,6 +11,45 @@ import (
 	"time"
 )
 
+const anonymizeUser = `-- name: AnonymizeUser :exec
+UPDATE users
+SET updated_at = $1, deleted_at = $2, full_name = $3, email = $4, phone = NULL, username = $5,
+    password = $6, api_key = $7, api_key_expires_at = $8,
+    email_verified_at = NULL, email_verification_sent_at = NULL, tokens_invalid_before = $9,
+    role = 'guest', failed_login_attempts = 0, locked_until = NULL,
+    totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0
+WHERE id = $10
+`
+
+type AnonymizeUserParams struct {
+	UpdatedAt           time.Time
+	DeletedAt           sql.NullTime
+	FullName            string
+	Email               string
+	Username            string
+	Password            string
+	ApiKey              string
+	ApiKeyExpiresAt     time.Time
+	TokensInvalidBefore sql.NullTime
+	ID                  string
+}
+
+func (q *Queries) AnonymizeUser(ctx context.Context, arg AnonymizeUserParams) error {
+	_, err := q.db.ExecContext(ctx, anonymizeUser,
+		arg.UpdatedAt,
+		arg.DeletedAt,
+		arg.FullName,
+		arg.Email,
+		arg.Username,
+		arg.Password,
+		arg.ApiKey,
+		arg.ApiKeyExpiresAt,
+		arg.TokensInvalidBefore,
+		arg.ID,
+	)
+	return err
+}
+
 const checkUserExistsByEmail = `-- name: CheckUserExistsByEmail :one
 SELECT EXISTS (SELECT email FROM users WHERE email = $1)
 `
@@ -44,6 +83,27 @@ func (q *Queries) CheckUserExistsByUsername(ctx context.Context, username string
 	return exists, err
 }
 
+const claimEmailVerificationSlot = `-- name: ClaimEmailVerificationSlot :execrows
+UPDATE users
+SET email_verification_sent_at = $1
+WHERE id = $2
+AND (email_verification_sent_at IS NULL OR email_verification_sent_at < $3)
+`
+
+type ClaimEmailVerificationSlotParams struct {
+	SentAt     sql.NullTime
+	ID         string
+	SentBefore sql.NullTime
+}
+
+func (q *Queries) ClaimEmailVerificationSlot(ctx context.Context, arg ClaimEmailVerificationSlotParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimEmailVerificationSlot, arg.SentAt, arg.ID, arg.SentBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createUser = `-- name: CreateUser :exec
 INSERT INTO users (id, created_at, updated_at, full_name, email, username, password, api_key, api_key_expires_at)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
@@ -76,8 +136,124 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
 	return err
 }
 
+const disableUserTotp = `-- name: DisableUserTotp :exec
+UPDATE users
+SET updated_at = $1, totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0
+WHERE id = $2
+`
+
+type DisableUserTotpParams struct {
+	UpdatedAt time.Time
+	ID        string
+}
+
+func (q *Queries) DisableUserTotp(ctx context.Context, arg DisableUserTotpParams) error {
+	_, err := q.db.ExecContext(ctx, disableUserTotp, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const enableUserTotp = `-- name: EnableUserTotp :exec
+UPDATE users
+SET updated_at = $1, totp_enabled_at = $2, totp_last_counter = $3
+WHERE id = $4
+`
+
+type EnableUserTotpParams struct {
+	UpdatedAt       time.Time
+	TotpEnabledAt   sql.NullTime
+	TotpLastCounter int64
+	ID              string
+}
+
+func (q *Queries) EnableUserTotp(ctx context.Context, arg EnableUserTotpParams) error {
+	_, err := q.db.ExecContext(ctx, enableUserTotp,
+		arg.UpdatedAt,
+		arg.TotpEnabledAt,
+		arg.TotpLastCounter,
+		arg.ID,
+	)
+	return err
+}
+
+const getLockedUsers = `-- name: GetLockedUsers :many
+SELECT id, username, email, failed_login_attempts, locked_until FROM users
+WHERE locked_until > $1
+ORDER BY locked_until DESC
+`
+
+type GetLockedUsersRow struct {
+	ID                  string
+	Username            string
+	Email               string
+	FailedLoginAttempts int32
+	LockedUntil         sql.NullTime
+}
+
+func (q *Queries) GetLockedUsers(ctx context.Context, lockedUntil sql.NullTime) ([]GetLockedUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getLockedUsers, lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLockedUsersRow
+	for rows.Next() {
+		var i GetLockedUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.FailedLoginAttempts,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at, email_verified_at, email_verification_sent_at, tokens_invalid_before, role, failed_login_attempts, locked_until, totp_secret, totp_enabled_at, totp_last_counter, deleted_at FROM users
+WHERE email = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FullName,
+		&i.Email,
+		&i.Phone,
+		&i.Username,
+		&i.Password,
+		&i.ApiKey,
+		&i.ApiKeyExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.EmailVerificationSentAt,
+		&i.TokensInvalidBefore,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at FROM users 
+SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at, email_verified_at, email_verification_sent_at, tokens_invalid_before, role, failed_login_attempts, locked_until, totp_secret, totp_enabled_at, totp_last_counter, deleted_at FROM users 
 WHERE id = $1
 LIMIT 1
 `
@@ -96,12 +272,22 @@ func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
 		&i.Password,
 		&i.ApiKey,
 		&i.ApiKeyExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.EmailVerificationSentAt,
+		&i.TokensInvalidBefore,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserByKey = `-- name: GetUserByKey :one
-SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at FROM users 
+SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at, email_verified_at, email_verification_sent_at, tokens_invalid_before, role, failed_login_attempts, locked_until, totp_secret, totp_enabled_at, totp_last_counter, deleted_at FROM users 
 WHERE api_key = $1
 LIMIT 1
 `
@@ -120,12 +306,22 @@ func (q *Queries) GetUserByKey(ctx context.Context, apiKey string) (User, error)
 		&i.Password,
 		&i.ApiKey,
 		&i.ApiKeyExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.EmailVerificationSentAt,
+		&i.TokensInvalidBefore,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at FROM users 
+SELECT id, created_at, updated_at, full_name, email, phone, username, password, api_key, api_key_expires_at, email_verified_at, email_verification_sent_at, tokens_invalid_before, role, failed_login_attempts, locked_until, totp_secret, totp_enabled_at, totp_last_counter, deleted_at FROM users 
 WHERE username = $1
 LIMIT 1
 `
@@ -144,10 +340,118 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Password,
 		&i.ApiKey,
 		&i.ApiKeyExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.EmailVerificationSentAt,
+		&i.TokensInvalidBefore,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const lockUser = `-- name: LockUser :exec
+UPDATE users
+SET failed_login_attempts = 0, locked_until = $1
+WHERE id = $2
+`
+
+type LockUserParams struct {
+	LockedUntil sql.NullTime
+	ID          string
+}
+
+func (q *Queries) LockUser(ctx context.Context, arg LockUserParams) error {
+	_, err := q.db.ExecContext(ctx, lockUser, arg.LockedUntil, arg.ID)
+	return err
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :exec
+UPDATE users
+SET updated_at = $1, email_verified_at = $2
+WHERE id = $3 AND email = $4
+`
+
+type MarkUserEmailVerifiedParams struct {
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
+	ID              string
+	Email           string
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) error {
+	_, err := q.db.ExecContext(ctx, markUserEmailVerified,
+		arg.UpdatedAt,
+		arg.EmailVerifiedAt,
+		arg.ID,
+		arg.Email,
+	)
+	return err
+}
+
+const recordFailedLogin = `-- name: RecordFailedLogin :one
+UPDATE users
+SET failed_login_attempts = failed_login_attempts + 1
+WHERE id = $1
+RETURNING failed_login_attempts
+`
+
+func (q *Queries) RecordFailedLogin(ctx context.Context, id string) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFailedLogin, id)
+	var failed_login_attempts int32
+	err := row.Scan(&failed_login_attempts)
+	return failed_login_attempts, err
+}
+
+const resetFailedLogins = `-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_login_attempts = 0, locked_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ResetFailedLogins(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, resetFailedLogins, id)
+	return err
+}
+
+const setUserTotpSecret = `-- name: SetUserTotpSecret :exec
+UPDATE users
+SET updated_at = $1, totp_secret = $2, totp_enabled_at = NULL, totp_last_counter = 0
+WHERE id = $3
+`
+
+type SetUserTotpSecretParams struct {
+	UpdatedAt  time.Time
+	TotpSecret sql.NullString
+	ID         string
+}
+
+func (q *Queries) SetUserTotpSecret(ctx context.Context, arg SetUserTotpSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTotpSecret, arg.UpdatedAt, arg.TotpSecret, arg.ID)
+	return err
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :exec
+UPDATE users
+SET updated_at = $1, email = $2, email_verified_at = NULL, email_verification_sent_at = NULL
+WHERE id = $3
+`
+
+type UpdateUserEmailParams struct {
+	UpdatedAt time.Time
+	Email     string
+	ID        string
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserEmail, arg.UpdatedAt, arg.Email, arg.ID)
+	return err
+}
+
 const updateUserInfo = `-- name: UpdateUserInfo :exec
 UPDATE users
 SET updated_at = $1, full_name = $2, email = $3, phone = $4
@@ -195,3 +499,56 @@ func (q *Queries) UpdateUserKey(ctx context.Context, arg UpdateUserKeyParams) er
 	)
 	return err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = $1, password = $2
+WHERE id = $3
+`
+
+type UpdateUserPasswordParams struct {
+	UpdatedAt time.Time
+	Password  string
+	ID        string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.UpdatedAt, arg.Password, arg.ID)
+	return err
+}
+
+const updateUserTokensInvalidBefore = `-- name: UpdateUserTokensInvalidBefore :exec
+UPDATE users
+SET updated_at = $1, tokens_invalid_before = $2
+WHERE id = $3
+`
+
+type UpdateUserTokensInvalidBeforeParams struct {
+	UpdatedAt           time.Time
+	TokensInvalidBefore sql.NullTime
+	ID                  string
+}
+
+func (q *Queries) UpdateUserTokensInvalidBefore(ctx context.Context, arg UpdateUserTokensInvalidBeforeParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserTokensInvalidBefore, arg.UpdatedAt, arg.TokensInvalidBefore, arg.ID)
+	return err
+}
+
+const updateUserTotpCounter = `-- name: UpdateUserTotpCounter :execrows
+UPDATE users
+SET totp_last_counter = $1
+WHERE id = $2 AND totp_last_counter < $1
+`
+
+type UpdateUserTotpCounterParams struct {
+	TotpLastCounter int64
+	ID              string
+}
+
+func (q *Queries) UpdateUserTotpCounter(ctx context.Context, arg UpdateUserTotpCounterParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserTotpCounter, arg.TotpLastCounter, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
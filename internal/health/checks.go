@@ -0,0 +1,22 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/STaninnat/booking-backend/internal/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+// DatabaseCheck pings the database.
+func DatabaseCheck(db *sql.DB) Check {
+	return Check{Name: "database", Run: db.PingContext}
+}
+
+// MigrationsCheck fails while the database is missing migrations this
+// build expects.
+func MigrationsCheck(provider *goose.Provider) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		return migrations.CheckCurrent(ctx, provider)
+	}}
+}
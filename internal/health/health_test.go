@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessReportsEachCheck(t *testing.T) {
+	readiness := NewReadiness(20*time.Millisecond,
+		Check{Name: "database", Run: func(context.Context) error { return nil }},
+		Check{Name: "cache", Run: func(context.Context) error { return errors.New("dial tcp 10.0.0.5:6379: refused") }},
+		Check{Name: "slow", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	)
+
+	report, ok := readiness.Check(context.Background())
+
+	assert.False(t, ok)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, StatusFail, report.Checks["cache"].Status)
+	assert.Empty(t, report.Checks["cache"].Error, "causes must not leak into the response")
+	assert.Equal(t, StatusFail, report.Checks["slow"].Status)
+	assert.Contains(t, report.Checks["slow"].Error, "timed out")
+}
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	called := false
+	readiness := NewReadiness(time.Second, Check{Name: "database", Run: func(context.Context) error {
+		called = true
+		return nil
+	}})
+
+	_, ok := readiness.Check(context.Background())
+	assert.True(t, ok)
+
+	called = false
+	readiness.StartDraining()
+	report, ok := readiness.Check(context.Background())
+
+	assert.False(t, ok)
+	assert.False(t, called)
+	assert.Equal(t, StatusFail, report.Checks["shutdown"].Status)
+}
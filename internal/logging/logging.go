@@ -0,0 +1,100 @@
+// Package logging builds the service's slog logger and carries the
+// per-request logger through the context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged. Keys
+// ending in _token, _secret or _password are redacted as well.
+var sensitiveKeys = map[string]struct{}{
+	"password":      {},
+	"token":         {},
+	"secret":        {},
+	"api_key":       {},
+	"apikey":        {},
+	"authorization": {},
+	"cookie":        {},
+	"set-cookie":    {},
+	"code":          {},
+	"recovery_code": {},
+	"code_verifier": {},
+}
+
+// jwtPattern matches compact JWS tokens embedded in any logged string, such
+// as an error message quoting the token it failed to parse.
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// New returns a logger writing to w. format is "json" or "text"; level is
+// one of debug, info, warn or error.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redact,
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, want json or text", format)
+	}
+}
+
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		if value := attr.Value.String(); jwtPattern.MatchString(value) {
+			return slog.String(attr.Key, jwtPattern.ReplaceAllString(value, redacted))
+		}
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok && jwtPattern.MatchString(err.Error()) {
+			return slog.String(attr.Key, jwtPattern.ReplaceAllString(err.Error(), redacted))
+		}
+	}
+
+	return attr
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := sensitiveKeys[key]; ok {
+		return true
+	}
+	return strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "_password")
+}
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying logger.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored by WithContext, or slog.Default()
+// outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/logging"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email. Production deployments plug in a real
+// provider; LogMailer and FileMailer are meant for local development.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by kind ("log" or "file"). An empty kind
+// falls back to logging.
+func New(kind, dir string) (Mailer, error) {
+	switch kind {
+	case "", "log":
+		return LogMailer{}, nil
+	case "file":
+		if dir == "" {
+			dir = "mail"
+		}
+		return FileMailer{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", kind)
+	}
+}
+
+// linkToken matches the token parameter of the links in reset and
+// verification emails.
+var linkToken = regexp.MustCompile(`([?&]token=)[^&\s]+`)
+
+// LogMailer writes every message to the request logger. Link tokens are
+// redacted, so the links in it can't be used; FileMailer keeps them.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	logging.FromContext(ctx).Info("mail sent",
+		"to", msg.To,
+		"subject", msg.Subject,
+		"body", linkToken.ReplaceAllString(msg.Body, "${1}[REDACTED]"),
+	)
+	return nil
+}
+
+// FileMailer writes every message to its own .eml file in Dir.
+type FileMailer struct {
+	Dir string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o750); err != nil {
+		return fmt.Errorf("couldn't create mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+
+	if err := os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("couldn't write message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := FileMailer{Dir: dir}
+
+	err := m.Send(context.Background(), Message{To: "guest@example.com", Subject: "Hello", Body: "Body text"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: guest@example.com")
+	assert.Contains(t, string(content), "Subject: Hello")
+	assert.Contains(t, string(content), "Body text")
+}
+
+func TestNewRejectsUnknownMailer(t *testing.T) {
+	_, err := New("carrier-pigeon", "")
+	assert.Error(t, err)
+}
+
+func TestLogMailerRedactsLinkTokens(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.WithContext(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)))
+
+	err := LogMailer{}.Send(ctx, Message{
+		To:      "guest@example.com",
+		Subject: "Reset your password",
+		Body:    "Open the link below.\n\nhttp://localhost:3000/reset-password?token=abc123def456&lang=en",
+	})
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "guest@example.com")
+	assert.Contains(t, out, "reset-password?token=[REDACTED]&lang=en")
+	assert.NotContains(t, out, "abc123def456")
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandlerExposesCounters(t *testing.T) {
+	m := New()
+	m.ObserveRequest(http.MethodGet, "/v1/rooms/{id}", http.StatusNotFound, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "", http.StatusNotFound, time.Millisecond)
+	m.ObserveRequest("BREW", "", http.StatusMethodNotAllowed, time.Millisecond)
+	m.ObserveRequest("get", "", http.StatusMethodNotAllowed, time.Millisecond)
+	m.BookingCreated()
+	m.BookingConflict()
+	m.Signin(SigninPassword)
+	m.SigninFailed(SigninTwoFactor)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	for _, want := range []string{
+		`booking_http_requests_total{method="GET",route="/v1/rooms/{id}",status_class="4xx"} 1`,
+		`booking_http_requests_total{method="GET",route="unmatched",status_class="4xx"} 1`,
+		`booking_http_requests_total{method="other",route="unmatched",status_class="4xx"} 2`,
+		`booking_http_request_duration_seconds_count{method="GET",route="/v1/rooms/{id}"} 1`,
+		`booking_bookings_created_total 1`,
+		`booking_booking_conflicts_total 1`,
+		`booking_signins_total{method="password"} 1`,
+		`booking_signin_failures_total{method="two_factor"} 1`,
+	} {
+		assert.Contains(t, string(body), want)
+	}
+}
+
+func TestNilMetricsIsNoop(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.ObserveRequest(http.MethodGet, "/", http.StatusOK, time.Millisecond)
+		m.BookingCreated()
+		m.BookingConflict()
+		m.Signin(SigninOIDC)
+		m.SigninFailed(SigninPassword)
+		m.RegisterDB(nil)
+	})
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/STaninnat/booking-backend/sql/schema"
+	"github.com/pressly/goose/v3"
+)
+
+// NewProvider returns a goose provider for the embedded sql/schema
+// migrations. Don't Close it: that would close db as well.
+func NewProvider(db *sql.DB) (*goose.Provider, error) {
+	return goose.NewProvider(goose.DialectPostgres, db, schema.FS)
+}
+
+// CheckCurrent returns an error when the database is missing migrations the
+// code expects. A database ahead of the code is accepted, so an older
+// release keeps working during a rolling deploy.
+func CheckCurrent(ctx context.Context, provider *goose.Provider) error {
+	current, target, err := provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("get schema version: %w", err)
+	}
+	if current < target {
+		return fmt.Errorf("schema version %d is behind the expected %d", current, target)
+	}
+	return nil
+}
+
+// Run executes one `booking migrate` command: "up" applies every pending
+// migration, "down" rolls back the latest one and "status" lists them all.
+// Progress is written to w.
+func Run(ctx context.Context, provider *goose.Provider, command string, w io.Writer) error {
+	switch command {
+	case "up":
+		results, err := provider.Up(ctx)
+		printResults(w, results)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		return nil
+	case "down":
+		result, err := provider.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Fprintln(w, "no migrations to roll back")
+			return nil
+		}
+		if result != nil {
+			printResults(w, []*goose.MigrationResult{result})
+		}
+		return err
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(w, statuses)
+	default:
+		return fmt.Errorf("unknown migrate command %q: expected up, down or status", command)
+	}
+}
+
+func printResults(w io.Writer, results []*goose.MigrationResult) {
+	for _, result := range results {
+		fmt.Fprintln(w, result)
+	}
+}
+
+func printStatus(w io.Writer, statuses []*goose.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	// sql.Open doesn't connect, so loading the sources needs no database.
+	db, err := sql.Open("postgres", "postgres://localhost:1/unused?sslmode=disable")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := NewProvider(db)
+	require.NoError(t, err)
+
+	sources := provider.ListSources()
+	require.NotEmpty(t, sources)
+	for i, source := range sources {
+		assert.Equal(t, int64(i+1), source.Version, "migrations must be numbered without gaps")
+	}
+}
+
+func TestRunRejectsUnknownCommand(t *testing.T) {
+	err := Run(context.Background(), nil, "sideways", nil)
+	assert.ErrorContains(t, err, "unknown migrate command")
+}
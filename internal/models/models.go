@@ -2,7 +2,7 @@ package models
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"strconv"
 	"time"
 
@@ -22,7 +22,7 @@ type Room struct {
 func DBRoomToRoom(room database.Room) Room {
 	price, err := strconv.ParseFloat(room.Price, 64)
 	if err != nil {
-		log.Printf("couldn't parse to float: %v\n", err)
+		slog.Error("couldn't parse room price", "room_id", room.ID, "error", err)
 		return Room{}
 	}
 
@@ -0,0 +1,16 @@
+package models
+
+import "slices"
+
+const (
+	RoleGuest = "guest"
+	RoleStaff = "staff"
+	RoleAdmin = "admin"
+)
+
+// Roles lists every role a user can hold.
+var Roles = []string{RoleGuest, RoleStaff, RoleAdmin}
+
+func IsValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
@@ -0,0 +1,331 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID     = "booking-client"
+	testClientSecret = "booking-secret"
+	testRedirectURL  = "http://localhost:8080/v1/auth/oidc/test/callback"
+)
+
+// fakeProvider is a minimal stand-in OIDC provider. It issues a code from
+// its authorization endpoint and redeems it for an ID token once, checking
+// the PKCE verifier and client credentials like a real provider would.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]pendingCode
+
+	// claims lets a test tamper with the ID token before it is signed.
+	claims func(jwt.MapClaims)
+}
+
+type pendingCode struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	fp := &fakeProvider{t: t, key: key, kid: "provider-1", codes: map[string]pendingCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 fp.server.URL,
+			"authorization_endpoint": fp.server.URL + "/authorize",
+			"token_endpoint":         fp.server.URL + "/token",
+			"jwks_uri":               fp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		fp.mu.Lock()
+		defer fp.mu.Unlock()
+		writeJSON(w, http.StatusOK, security.JWKSet{Keys: []security.JWK{{
+			Kty: "RSA",
+			Kid: fp.kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(fp.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(fp.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != testClientID {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+
+		code, err := GenerateCodeVerifier()
+		require.NoError(t, err)
+
+		fp.mu.Lock()
+		fp.codes[code] = pendingCode{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+		fp.mu.Unlock()
+
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != testClientID || clientSecret != testClientSecret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		fp.mu.Lock()
+		pending, found := fp.codes[r.PostFormValue("code")]
+		delete(fp.codes, r.PostFormValue("code"))
+		fp.mu.Unlock()
+
+		if !found || CodeChallengeS256(r.PostFormValue("code_verifier")) != pending.challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"access_token": "provider-access-token",
+			"token_type":   "Bearer",
+			"id_token":     fp.idToken(pending.nonce),
+		})
+	})
+
+	fp.server = httptest.NewServer(mux)
+	t.Cleanup(fp.server.Close)
+	return fp
+}
+
+func (fp *fakeProvider) idToken(nonce string) string {
+	claims := jwt.MapClaims{
+		"iss":            fp.server.URL,
+		"sub":            "provider-user-1",
+		"aud":            testClientID,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          "guest@example.com",
+		"email_verified": true,
+		"name":           "Guest User",
+	}
+	if fp.claims != nil {
+		fp.claims(claims)
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = fp.kid
+	signed, err := token.SignedString(fp.key)
+	require.NoError(fp.t, err)
+	return signed
+}
+
+// rotateKey swaps the provider's signing key the way a real provider
+// would during key rotation.
+func (fp *fakeProvider) rotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(fp.t, err)
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.key = key
+	fp.kid = "provider-2"
+}
+
+func (fp *fakeProvider) provider() *Provider {
+	return NewProvider(Config{
+		Name:         "test",
+		Issuer:       fp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, fp.server.Client())
+}
+
+// authorize runs the browser leg of the flow and returns the code and state
+// the provider sent back to the redirect URL.
+func (fp *fakeProvider) authorize(t *testing.T, p *Provider, state, nonce, verifier string) (string, string) {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, CodeChallengeS256(verifier))
+	require.NoError(t, err)
+
+	client := fp.server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, testRedirectURL, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := fp.provider()
+	ctx := context.Background()
+
+	verifier, err := GenerateCodeVerifier()
+	require.NoError(t, err)
+
+	code, state := fp.authorize(t, p, "state-1", "nonce-1", verifier)
+	assert.Equal(t, "state-1", state)
+
+	rawIDToken, err := p.Exchange(ctx, code, verifier)
+	require.NoError(t, err)
+
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "provider-user-1", claims.Subject)
+	assert.Equal(t, "guest@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, "Guest User", claims.Name)
+
+	// Codes are single use.
+	_, err = p.Exchange(ctx, code, verifier)
+	assert.Error(t, err)
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := fp.provider()
+
+	verifier, err := GenerateCodeVerifier()
+	require.NoError(t, err)
+	code, _ := fp.authorize(t, p, "state", "nonce", verifier)
+
+	_, err = p.Exchange(context.Background(), code, "not-the-verifier")
+	assert.Error(t, err)
+}
+
+func TestVerifyIDTokenRejections(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		nonce  string
+		tamper func(jwt.MapClaims)
+		sign   func(fp *fakeProvider, claims jwt.MapClaims) string
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce"},
+		{name: "wrong audience", nonce: "nonce", tamper: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "wrong issuer", nonce: "nonce", tamper: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", nonce: "nonce", tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "missing subject", nonce: "nonce", tamper: func(c jwt.MapClaims) { delete(c, "sub") }},
+		{name: "foreign azp", nonce: "nonce", tamper: func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "another-client"}
+			c["azp"] = "another-client"
+		}},
+		{name: "signed by unknown key", nonce: "nonce", sign: func(fp *fakeProvider, claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = fp.kid
+			signed, err := token.SignedString(otherKey)
+			require.NoError(t, err)
+			return signed
+		}},
+		{name: "hmac with public key", nonce: "nonce", sign: func(fp *fakeProvider, claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = fp.kid
+			signed, err := token.SignedString(fp.key.N.Bytes())
+			require.NoError(t, err)
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := newFakeProvider(t)
+			p := fp.provider()
+
+			var rawIDToken string
+			if tt.sign != nil {
+				claims := jwt.MapClaims{
+					"iss": fp.server.URL, "sub": "provider-user-1", "aud": testClientID, "nonce": "nonce",
+					"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+				}
+				rawIDToken = tt.sign(fp, claims)
+			} else {
+				fp.claims = tt.tamper
+				rawIDToken = fp.idToken("nonce")
+			}
+
+			_, err := p.VerifyIDToken(context.Background(), rawIDToken, tt.nonce)
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+}
+
+func TestVerifyIDTokenPicksUpRotatedKeys(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := fp.provider()
+	ctx := context.Background()
+
+	_, err := p.VerifyIDToken(ctx, fp.idToken("nonce"), "nonce")
+	require.NoError(t, err)
+
+	fp.rotateKey()
+	// Pretend the cached keys are old enough to be refreshed.
+	p.keysFetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+
+	_, err = p.VerifyIDToken(ctx, fp.idToken("nonce"), "nonce")
+	assert.NoError(t, err)
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := NewProvider(Config{Issuer: fp.server.URL + "/", ClientID: testClientID}, fp.server.Client())
+
+	_, err := p.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.Error(t, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestAuthCodeURLFreshLogin(t *testing.T) {
+	fp := newFakeProvider(t)
+
+	authURL, err := fp.provider().AuthCodeURL(context.Background(), "state", "nonce", "challenge", WithFreshLogin())
+	require.NoError(t, err)
+
+	location, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "login", location.Query().Get("prompt"))
+	assert.Equal(t, "0", location.Query().Get("max_age"))
+}
@@ -1,118 +1,337 @@
 package main
 
 import (
-	"database/sql"
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/STaninnat/booking-backend/handlers"
 	"github.com/STaninnat/booking-backend/internal/config"
 	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/health"
+	"github.com/STaninnat/booking-backend/internal/mailer"
+	"github.com/STaninnat/booking-backend/internal/metrics"
+	"github.com/STaninnat/booking-backend/internal/migrations"
+	"github.com/STaninnat/booking-backend/internal/models"
+	"github.com/STaninnat/booking-backend/internal/tracing"
 	"github.com/STaninnat/booking-backend/middlewares"
+	"github.com/STaninnat/booking-backend/security"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
-	"github.com/joho/godotenv"
 
 	_ "github.com/lib/pq"
 )
 
+// readinessCheckTimeout bounds each /readyz dependency check, so a hung
+// database fails the probe instead of stalling it.
+const readinessCheckTimeout = 2 * time.Second
+
 func main() {
-	if err := godotenv.Load(".env.development"); err != nil {
-		log.Printf("warning: assuming default configuration. '.env.development' unreadable: %v\n", err)
+	configFile := flag.String("config", "", "optional config file of KEY=value lines (default $CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	flag.Parse()
+
+	// Migrations need only the database, so they are dispatched before the
+	// server's settings are validated.
+	if flag.Arg(0) == "migrate" && !*printConfig {
+		migrateCfg, err := config.LoadMigrate(*configFile)
+		if err != nil {
+			fatal("invalid configuration", err)
+		}
+		if err := runMigrate(migrateCfg, flag.Args()[1:]); err != nil {
+			fatal("migration failed", err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fatal("invalid configuration", err)
 	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("failed to print configuration", err)
+		}
+		return
+	}
+
+	logger, err := cfg.Log.NewLogger()
+	if err != nil {
+		fatal("invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		log.Println("warning: PORT environment variable is not set")
+	for _, warning := range cfg.Warnings() {
+		slog.Warn("unsafe configuration: " + warning)
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Println("warning: JWT_SECRET environment variable is not set")
+	jwtKeys, err := security.LoadKeyRing(cfg.JWTKeysDir, cfg.JWTActiveKeyID, cfg.JWTRetiredKeyIDs, string(cfg.JWTSecret))
+	if err != nil {
+		fatal("failed to load JWT signing keys", err)
 	}
 
-	refreshSecret := os.Getenv("REFRESH_SECRET")
-	if refreshSecret == "" {
-		log.Println("warning: REFRESH_SECRET environment variable is not set")
+	refreshKeys, err := security.NewHMACKeyRing("refresh", string(cfg.RefreshSecret))
+	if err != nil {
+		fatal("failed to load refresh token key", err)
+	}
+
+	mail, err := mailer.New(cfg.Mailer, cfg.MailerDir)
+	if err != nil {
+		fatal("invalid mailer configuration", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
 	}
 
 	apicfg := config.ApiConfig{
-		JWTSecret:     jwtSecret,
-		RefreshSecret: refreshSecret,
+		JWTKeys:     jwtKeys,
+		RefreshKeys: refreshKeys,
+		Token:       cfg.Token,
+		Mailer:      mail,
+		FrontendURL: cfg.FrontendURL,
+		TOTPIssuer:  cfg.TOTPIssuer,
+
+		OIDCProviders: config.NewOIDCProviders(cfg.OIDC),
+
+		LoginThrottle:  security.NewLoginThrottle(10, time.Second, 5*time.Minute, time.Hour),
+		PasswordPolicy: cfg.PasswordPolicy,
+		PasswordHasher: cfg.PasswordHasher,
+
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
+
+		Metrics:    metrics.New(),
+		Background: &sync.WaitGroup{},
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Println("warning: DATABASE_URL environment variable is not set")
-		log.Println("running without CRUD endpoints")
+	var readinessChecks []health.Check
+	if cfg.DatabaseURL == "" {
+		slog.Warn("DATABASE_URL environment variable is not set, running without CRUD endpoints")
 	} else {
-		db, err := sql.Open("postgres", dbURL)
+		db, err := openDB(cfg)
 		if err != nil {
-			log.Fatalf("warning: can't connect to database: %v\n", err)
-		}
-
-		if err := db.Ping(); err != nil {
-			log.Fatalf("failed to ping database: %v\n", err)
+			fatal("can't connect to database", err)
 		}
 
-		dbQueries := database.New(db)
+		dbQueries := database.New(tracing.WrapDB(db))
 		apicfg.DB = dbQueries
 		apicfg.DBConn = db
-		log.Println("Connected to database successfully!")
+		apicfg.Metrics.RegisterDB(db)
+		slog.Info("connected to database")
+
+		migrationProvider, err := migrations.NewProvider(db)
+		if err != nil {
+			fatal("failed to load migrations", err)
+		}
+		if cfg.AutoMigrate {
+			results, err := migrationProvider.Up(context.Background())
+			if err != nil {
+				fatal("failed to apply migrations", err)
+			}
+			for _, result := range results {
+				slog.Info("applied migration", "version", result.Source.Version, "file", result.Source.Path, "duration", result.Duration)
+			}
+		}
+		if err := migrations.CheckCurrent(context.Background(), migrationProvider); err != nil {
+			fatal("database schema is out of date, run `booking migrate up` or set AUTO_MIGRATE", err)
+		}
+		readinessChecks = append(readinessChecks,
+			health.DatabaseCheck(db),
+			health.MigrationsCheck(migrationProvider),
+		)
 	}
+	apicfg.Readiness = health.NewReadiness(readinessCheckTimeout, readinessChecks...)
 
 	router := chi.NewRouter()
 
-	// router.Use(middleware.Logger)
+	router.Use(middlewares.MiddlewareRequestID)
+	router.Use(middlewares.MiddlewareTracing)
+	router.Use(middlewares.MiddlewareLogger(logger))
+	router.Use(middlewares.MiddlewareMetrics(apicfg.Metrics))
 	router.Use(middleware.Recoverer)
 
-	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	router.Use(cors.Handler(cfg.CORS))
+	router.Use(middlewares.MiddlewareCSRF(cfg.TrustedOrigins))
+
+	router.Get("/livez", middlewares.Handle(&apicfg, handlers.HandlerLiveness))
+	router.Get("/readyz", middlewares.Handle(&apicfg, handlers.HandlerReadiness))
+	router.Get("/.well-known/jwks.json", middlewares.Handle(&apicfg, handlers.HandlerJWKS))
+
+	rateLimitStore := middlewares.NewMemoryRateLimitStore()
+	authRateLimit := middlewares.MiddlewareRateLimit(rateLimitStore, middlewares.RateLimitPolicy{
+		Name:   "auth",
+		Limit:  cfg.RateLimits.Auth.Limit,
+		Period: cfg.RateLimits.Auth.Period,
+		Key:    middlewares.RateLimitByIP,
+	})
+	bookingsRateLimit := middlewares.MiddlewareRateLimit(rateLimitStore, middlewares.RateLimitPolicy{
+		Name:   "bookings",
+		Limit:  cfg.RateLimits.Bookings.Limit,
+		Period: cfg.RateLimits.Bookings.Period,
+		Key:    middlewares.RateLimitByUser(&apicfg),
+	})
 
 	v1Router := chi.NewRouter()
+	v1Router.Use(middlewares.MiddlewareRateLimit(rateLimitStore, middlewares.RateLimitPolicy{
+		Name:   "default",
+		Limit:  cfg.RateLimits.Default.Limit,
+		Period: cfg.RateLimits.Default.Period,
+		Key:    middlewares.RateLimitByUser(&apicfg),
+	}))
 	if apicfg.DB != nil {
-		v1Router.Get("/healthz", handlers.HandlerReadiness)
-		v1Router.Get("/error", handlers.HandlerError)
+		v1Router.Get("/healthz", middlewares.Handle(&apicfg, handlers.HandlerReadiness))
+		v1Router.Get("/error", middlewares.Handle(&apicfg, handlers.HandlerError))
 
 		v1Router.Get("/auth/check", middlewares.HandlerCheckAuth(&apicfg))
+		v1Router.With(authRateLimit).Get("/auth/oidc/{provider}/login", middlewares.Handle(&apicfg, handlers.HandlerOIDCLogin))
+		v1Router.With(authRateLimit).Get("/auth/oidc/{provider}/callback", middlewares.Handle(&apicfg, handlers.HandlerOIDCCallback))
+
+		v1Router.With(authRateLimit).Post("/user/signup", middlewares.Handle(&apicfg, handlers.HandlerCreateUser))
+		v1Router.With(authRateLimit).Post("/user/signin", middlewares.Handle(&apicfg, handlers.HandlerSignin))
+		v1Router.With(authRateLimit).Post("/user/signin/2fa", middlewares.Handle(&apicfg, handlers.HandlerSigninTwoFactor))
+		v1Router.Post("/user/signout", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerSignout))
+		v1Router.With(authRateLimit).Post("/user/refresh-key", middlewares.Handle(&apicfg, handlers.HandlerRefreshKey))
+		v1Router.With(authRateLimit).Post("/user/password/forgot", middlewares.Handle(&apicfg, handlers.HandlerForgotPassword))
+		v1Router.With(authRateLimit).Post("/user/password/reset", middlewares.Handle(&apicfg, handlers.HandlerResetPassword))
+		v1Router.With(authRateLimit).Post("/user/email/verify", middlewares.Handle(&apicfg, handlers.HandlerVerifyEmail))
+		v1Router.With(authRateLimit).Post("/user/email/resend", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerResendVerificationEmail))
+		v1Router.Put("/user/password", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerChangePassword))
+		v1Router.Put("/user/email", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerChangeEmail))
+		v1Router.Put("/user/profile", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerUpdateProfile))
+		v1Router.Get("/user/export", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerExportUserData))
+		v1Router.Delete("/user", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerDeleteUser))
+		v1Router.With(authRateLimit).Get("/user/reauth/oidc/{provider}", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerOIDCReauth))
+		v1Router.Get("/user/2fa", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerTwoFactorStatus))
+		v1Router.Post("/user/2fa/enroll", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerTwoFactorEnroll))
+		v1Router.Post("/user/2fa/confirm", middlewares.MiddlewareAuthAllowTwoFactorSetup(&apicfg, handlers.HandlerTwoFactorConfirm))
+		v1Router.Post("/user/2fa/disable", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerTwoFactorDisable))
+		v1Router.Post("/user/2fa/recovery-codes", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerRegenerateRecoveryCodes))
 
-		v1Router.Post("/user/signup", handlers.HandlerCreateUser(&apicfg))
-		v1Router.Post("/user/signin", handlers.HandlerSignin(&apicfg))
-		v1Router.Post("/user/signout", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerSignout))
-		v1Router.Post("/user/refresh-key", handlers.HandlerRefreshKey(&apicfg))
+		v1Router.Get("/admin/users/locked", middlewares.MiddlewareRole(&apicfg, handlers.HandlerGetLockedUsers, models.RoleAdmin))
+		v1Router.Get("/admin/users/{id}/lockout", middlewares.MiddlewareRole(&apicfg, handlers.HandlerGetUserLockout, models.RoleAdmin))
+		v1Router.Post("/admin/users/{id}/unlock", middlewares.MiddlewareRole(&apicfg, handlers.HandlerUnlockUser, models.RoleAdmin))
+		v1Router.Get("/admin/roles/policies", middlewares.MiddlewareRole(&apicfg, handlers.HandlerGetRolePolicies, models.RoleAdmin))
+		v1Router.Put("/admin/roles/{role}/policy", middlewares.MiddlewareRole(&apicfg, handlers.HandlerUpdateRolePolicy, models.RoleAdmin))
 
-		v1Router.Post("/rooms", handlers.HandlerCreateRoom(&apicfg))
-		v1Router.Get("/rooms", handlers.HandlerGetAllRooms(&apicfg))
+		v1Router.Post("/rooms", middlewares.Handle(&apicfg, handlers.HandlerCreateRoom))
+		v1Router.Get("/rooms", middlewares.Handle(&apicfg, handlers.HandlerGetAllRooms))
 		v1Router.Get("/rooms/{id}", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerGetRoom))
 		v1Router.Get("/rooms/{room_id}/calendar", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerGetRoomCalendar))
 
-		v1Router.Post("/bookings", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerCreateBooking))
+		v1Router.With(bookingsRateLimit).Post("/bookings", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerCreateBooking))
 		v1Router.Get("/bookings", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerGetAllBookings))
 		v1Router.Get("/bookings/user/{user_id}", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerGetBookingsByUserID))
 		v1Router.Get("/bookings/room/{room_id}", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerGetBookingsByRoomID))
-		v1Router.Delete("/bookings/{id}", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerDeleteBooking))
+		v1Router.With(bookingsRateLimit).Delete("/bookings/{id}", middlewares.MiddlewareAuth(&apicfg, handlers.HandlerDeleteBooking))
 	}
 
 	router.Mount("/v1", v1Router)
+
 	srv := &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + cfg.Port,
 		Handler:      router,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	servers := []*http.Server{srv}
+
+	// With METRICS_ADDR set, /metrics is only served on that (internal)
+	// address instead of next to the public API.
+	if cfg.MetricsAddr != "" {
+		metricsRouter := chi.NewRouter()
+		metricsRouter.Handle("/metrics", apicfg.Metrics.Handler())
+		servers = append(servers, &http.Server{
+			Addr:         cfg.MetricsAddr,
+			Handler:      metricsRouter,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		})
+	} else {
+		router.Handle("/metrics", apicfg.Metrics.Handler())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErrs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			slog.Info("serving", "addr", server.Addr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrs <- fmt.Errorf("%s: %w", server.Addr, err)
+			}
+		}()
+	}
 
-	log.Printf("Serving on port: %s\n", port)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server failed: %v\n", err)
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err := <-serverErrs:
+		slog.Error("server failed", "error", err)
+		exitCode = 1
 	}
+	// A second signal skips the graceful shutdown.
+	stop()
+
+	shutdown(&apicfg, servers, cfg.Shutdown)
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("couldn't flush traces", "error", err)
+	}
+	os.Exit(exitCode)
+}
+
+// shutdown fails readiness, waits for the drain delay so load balancers
+// stop routing here, then lets in-flight requests finish before closing
+// the database.
+func shutdown(apicfg *config.ApiConfig, servers []*http.Server, settings config.ShutdownSettings) {
+	apicfg.Readiness.StartDraining()
+	time.Sleep(settings.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("couldn't shut down server gracefully", "addr", server.Addr, "error", err)
+		}
+	}
+
+	// Background work such as sending mail still needs the database.
+	done := make(chan struct{})
+	go func() {
+		apicfg.Background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("background tasks still running at shutdown")
+	}
+
+	if apicfg.DBConn != nil {
+		if err := apicfg.DBConn.Close(); err != nil {
+			slog.Warn("couldn't close database", "error", err)
+		}
+	}
+
+	slog.Info("shutdown complete")
+}
+
+// fatal logs err and exits, since slog has no Fatal level.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -1,45 +1,34 @@
 package middlewares
 
 import (
-	"log"
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/STaninnat/booking-backend/internal/config"
-	"github.com/STaninnat/booking-backend/security"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/STaninnat/booking-backend/internal/logging"
 )
 
 func HandlerCheckAuth(cfg *config.ApiConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tokenCookie, err := r.Cookie("access_token")
-		if err != nil {
-			log.Println("Couldn't find token error:", err)
-			RespondWithJSON(w, http.StatusUnauthorized, map[string]bool{"isAuthenticated": false})
-			return
+		type authStatusResponse struct {
+			IsAuthenticated bool   `json:"isAuthenticated"`
+			Reason          string `json:"reason,omitempty"`
 		}
 
-		tokenString := tokenCookie.Value
-
-		claims, err := security.ValidateJWTToken(tokenString, cfg.JWTSecret)
+		_, reason, err := authenticate(cfg, r)
 		if err != nil {
-			if err == jwt.ErrTokenExpired {
-				log.Println("Token expired error:", err)
-				RespondWithJSON(w, http.StatusUnauthorized, map[string]bool{"isAuthenticated": false})
+			if errors.Is(err, errAuthLookup) {
+				logging.FromContext(r.Context()).Error("couldn't get user", "error", err)
+				RespondWithError(w, r, http.StatusInternalServerError, "internal_error", "Couldn't verify session")
 				return
 			}
 
-			log.Printf("Token validation error: %v\n", err)
-			RespondWithJSON(w, http.StatusUnauthorized, map[string]bool{"isAuthenticated": false})
-			return
-		}
-
-		if claims.ExpiresAt.Before(time.Now()) {
-			http.Error(w, "Token expired", http.StatusUnauthorized)
-			RespondWithJSON(w, http.StatusUnauthorized, map[string]bool{"isAuthenticated": false})
+			logging.FromContext(r.Context()).Debug("authentication check failed", "reason", reason, "error", err)
+			setAuthChallenge(w, reason)
+			RespondWithJSON(w, http.StatusUnauthorized, authStatusResponse{IsAuthenticated: false, Reason: reason})
 			return
 		}
 
-		RespondWithJSON(w, http.StatusOK, map[string]bool{"isAuthenticated": true})
+		RespondWithJSON(w, http.StatusOK, authStatusResponse{IsAuthenticated: true})
 	}
 }
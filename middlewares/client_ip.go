@@ -0,0 +1,17 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP returns the address of the connecting peer. Forwarding headers
+// are ignored on purpose: they are client-controlled and would let anyone
+// dodge per-IP limits.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
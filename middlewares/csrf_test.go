@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareCSRF(t *testing.T) {
+	handler := MiddlewareCSRF([]string{"https://app.example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	sessionCookie := &http.Cookie{Name: "access_token", Value: "token"}
+
+	tests := []struct {
+		name     string
+		method   string
+		origin   string
+		referer  string
+		bearer   bool
+		cookie   bool
+		expected int
+	}{
+		{name: "safe method", method: http.MethodGet, origin: "https://evil.example.net", cookie: true, expected: http.StatusNoContent},
+		{name: "trusted origin", method: http.MethodPost, origin: "https://app.example.com", cookie: true, expected: http.StatusNoContent},
+		{name: "trusted origin different case", method: http.MethodDelete, origin: "HTTPS://APP.example.com", cookie: true, expected: http.StatusNoContent},
+		{name: "same origin", method: http.MethodPost, origin: "http://api.example.com", cookie: true, expected: http.StatusNoContent},
+		{name: "trusted referer", method: http.MethodPost, referer: "https://app.example.com/bookings", cookie: true, expected: http.StatusNoContent},
+		{name: "untrusted origin", method: http.MethodPost, origin: "https://evil.example.net", cookie: true, expected: http.StatusForbidden},
+		{name: "sibling subdomain", method: http.MethodDelete, origin: "https://other.example.com", cookie: true, expected: http.StatusForbidden},
+		{name: "opaque origin", method: http.MethodPost, origin: "null", cookie: true, expected: http.StatusForbidden},
+		{name: "cookie without origin", method: http.MethodPost, cookie: true, expected: http.StatusForbidden},
+		{name: "login csrf without cookie", method: http.MethodPost, origin: "https://evil.example.net", expected: http.StatusForbidden},
+		{name: "non-browser client", method: http.MethodPost, expected: http.StatusNoContent},
+		{name: "bearer client", method: http.MethodPost, origin: "https://evil.example.net", bearer: true, cookie: true, expected: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://api.example.com/v1/bookings", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			if tt.cookie {
+				req.AddCookie(sessionCookie)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MaxRequestBodyBytes caps JSON request bodies. None of the API's requests
+// come anywhere near it.
+const MaxRequestBodyBytes = 1 << 20
+
+// DecodeJSON reads a single JSON object from the request body into dst and
+// runs Validate on it. The body must be application/json, at most
+// MaxRequestBodyBytes long and contain only fields that dst declares. Every
+// failing field is reported at once in the returned *Error.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return UnsupportedMediaTypeError("unsupported_media_type", "Content-Type must be application/json")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return decodeError(err)
+		}
+		return ValidationError("invalid_body", "Request body must contain a single JSON object")
+	}
+
+	if fieldErrs := Validate(dst); len(fieldErrs) > 0 {
+		return FieldValidationError(fieldErrs...)
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return PayloadTooLargeError("body_too_large", fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		return ValidationError("invalid_body", "Request body must not be empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return ValidationError("invalid_body", "Request body is not valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return InvalidFieldError(typeErr.Field, "invalid_type", fmt.Sprintf("%s must be a %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String())))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this one.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return InvalidFieldError(field, "unknown_field", field+" is not a recognised field")
+	default:
+		return ValidationError("invalid_body", "Request body must be a JSON object")
+	}
+}
+
+func jsonTypeName(kind string) string {
+	switch {
+	case kind == "string":
+		return "string"
+	case kind == "bool":
+		return "boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice" || kind == "array":
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type parameters struct {
+		Username string `json:"username" validate:"required"`
+		Password string `json:"password" validate:"required,min=8"`
+		Age      int    `json:"age"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		fields      []string
+	}{
+		{"valid", "application/json; charset=utf-8", `{"username":"guest","password":"long enough"}`, 0, "", nil},
+		{"wrong content type", "text/plain", `{"username":"guest","password":"long enough"}`, http.StatusUnsupportedMediaType, "unsupported_media_type", nil},
+		{"missing content type", "", `{}`, http.StatusUnsupportedMediaType, "unsupported_media_type", nil},
+		{"empty body", "application/json", ``, http.StatusBadRequest, "invalid_body", nil},
+		{"malformed", "application/json", `{"username":`, http.StatusBadRequest, "invalid_body", nil},
+		{"not an object", "application/json", `["guest"]`, http.StatusBadRequest, "invalid_body", nil},
+		{"unknown field", "application/json", `{"username":"guest","password":"long enough","admin":true}`, http.StatusBadRequest, "validation_failed", []string{"admin"}},
+		{"wrong type", "application/json", `{"username":"guest","password":"long enough","age":"old"}`, http.StatusBadRequest, "validation_failed", []string{"age"}},
+		{"trailing data", "application/json", `{"username":"guest","password":"long enough"} {}`, http.StatusBadRequest, "invalid_body", nil},
+		{"all rule failures", "application/json", `{"password":"short"}`, http.StatusBadRequest, "validation_failed", []string{"username", "password"}},
+		{"too large", "application/json", `{"username":"` + strings.Repeat("a", MaxRequestBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var params parameters
+			err := DecodeJSON(httptest.NewRecorder(), req, &params)
+			if tt.status == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, "guest", params.Username)
+				return
+			}
+
+			var appErr *Error
+			require.True(t, errors.As(err, &appErr))
+			assert.Equal(t, tt.status, appErr.Kind.Status())
+			assert.Equal(t, tt.code, appErr.Code)
+
+			var fields []string
+			for _, field := range appErr.Fields {
+				fields = append(fields, field.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package middlewares
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/logging"
+)
+
+// ErrorKind classifies an error returned by a handler. Each kind maps to
+// one status code.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindTooManyRequests
+	KindPayloadTooLarge
+	KindUnsupportedMediaType
+)
+
+func (k ErrorKind) Status() int {
+	switch k {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is what handlers return instead of writing an error response
+// themselves. Code and Detail are shown to the client; Err is only logged.
+type Error struct {
+	Kind       ErrorKind
+	Code       string
+	Detail     string
+	Fields     []FieldError
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func ValidationError(code, detail string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Detail: detail}
+}
+
+// FieldValidationError reports one or more invalid fields under the shared
+// validation_failed code.
+func FieldValidationError(fields ...FieldError) *Error {
+	return &Error{
+		Kind:   KindValidation,
+		Code:   "validation_failed",
+		Detail: "The request contains invalid fields",
+		Fields: fields,
+	}
+}
+
+// InvalidFieldError is FieldValidationError for a single field.
+func InvalidFieldError(field, code, detail string) *Error {
+	return FieldValidationError(FieldError{Field: field, Code: code, Detail: detail})
+}
+
+func UnauthorizedError(code, detail string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Detail: detail}
+}
+
+func ForbiddenError(code, detail string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Detail: detail}
+}
+
+func NotFoundError(code, detail string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Detail: detail}
+}
+
+func ConflictError(code, detail string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Detail: detail}
+}
+
+func TooManyRequestsError(code, detail string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindTooManyRequests, Code: code, Detail: detail, RetryAfter: retryAfter}
+}
+
+func PayloadTooLargeError(code, detail string) *Error {
+	return &Error{Kind: KindPayloadTooLarge, Code: code, Detail: detail}
+}
+
+func UnsupportedMediaTypeError(code, detail string) *Error {
+	return &Error{Kind: KindUnsupportedMediaType, Code: code, Detail: detail}
+}
+
+// InternalError hides err from the client behind detail and logs it.
+func InternalError(detail string, err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal_error", Detail: detail, Err: err}
+}
+
+type apihandler func(*config.ApiConfig, http.ResponseWriter, *http.Request) error
+
+// Handle adapts a handler that returns an error into an http.HandlerFunc.
+// Every request gets exactly one response: a returned error is written via
+// RespondWithHandlerError, and a handler that returns nil without writing
+// anything is answered with a 500 rather than an empty 200.
+func Handle(cfg *config.ApiConfig, handler apihandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		finishHandler(tw, r, handler(cfg, tw, r))
+	}
+}
+
+// RespondWithHandlerError writes the response for an error returned by a
+// handler. Errors that aren't *Error are treated as internal, except
+// sql.ErrNoRows which becomes a 404.
+func RespondWithHandlerError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *Error
+	if !errors.As(err, &appErr) {
+		if errors.Is(err, sql.ErrNoRows) {
+			appErr = NotFoundError("not_found", "Not found")
+		} else {
+			appErr = InternalError("Internal server error", err)
+		}
+	}
+
+	if appErr.Kind == KindInternal && appErr.Err != nil {
+		logging.FromContext(r.Context()).Error(appErr.Detail, "error", appErr.Err)
+	}
+	if appErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(appErr.RetryAfter)))
+	}
+
+	RespondWithProblem(w, r, Problem{
+		Status: appErr.Kind.Status(),
+		Code:   appErr.Code,
+		Detail: appErr.Detail,
+		Errors: appErr.Fields,
+	})
+}
+
+func finishHandler(w *trackingWriter, r *http.Request, err error) {
+	switch {
+	case err != nil && w.written:
+		logging.FromContext(r.Context()).Error("error after response was written", "error", err)
+	case err != nil:
+		RespondWithHandlerError(w, r, err)
+	case !w.written:
+		logging.FromContext(r.Context()).Error("handler returned without writing a response")
+		RespondWithError(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+// trackingWriter records whether anything has been written yet.
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMapsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"validation", ValidationError("invalid_input", "Invalid input"), http.StatusBadRequest, "invalid_input", "Invalid input"},
+		{"unauthorized", UnauthorizedError("invalid_credentials", "Invalid credentials"), http.StatusUnauthorized, "invalid_credentials", "Invalid credentials"},
+		{"forbidden", ForbiddenError("insufficient_permissions", "Insufficient permissions"), http.StatusForbidden, "insufficient_permissions", "Insufficient permissions"},
+		{"not found", NotFoundError("room_not_found", "Couldn't find room"), http.StatusNotFound, "room_not_found", "Couldn't find room"},
+		{"conflict", ConflictError("room_unavailable", "Room is already booked"), http.StatusConflict, "room_unavailable", "Room is already booked"},
+		{"internal hides cause", InternalError("Couldn't create room", errors.New("pq: connection refused")), http.StatusInternalServerError, "internal_error", "Couldn't create room"},
+		{"wrapped", fmt.Errorf("create booking: %w", ConflictError("room_unavailable", "Room is already booked")), http.StatusConflict, "room_unavailable", "Room is already booked"},
+		{"no rows", fmt.Errorf("get room: %w", sql.ErrNoRows), http.StatusNotFound, "not_found", "Not found"},
+		{"plain error", errors.New("boom"), http.StatusInternalServerError, "internal_error", "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Handle(&config.ApiConfig{}, func(*config.ApiConfig, http.ResponseWriter, *http.Request) error {
+				return tt.err
+			})
+
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/v1/rooms", nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, http.StatusText(tt.status), problem.Title)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.Equal(t, "/v1/rooms", problem.Instance)
+		})
+	}
+}
+
+func TestHandleSetsRetryAfter(t *testing.T) {
+	handler := Handle(&config.ApiConfig{}, func(*config.ApiConfig, http.ResponseWriter, *http.Request) error {
+		return TooManyRequestsError("rate_limited", "Too many requests, please try again later", 1500*time.Millisecond)
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+}
+
+func TestHandleAlwaysWritesResponse(t *testing.T) {
+	handler := Handle(&config.ApiConfig{}, func(*config.ApiConfig, http.ResponseWriter, *http.Request) error {
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotEmpty(t, rec.Body.String())
+}
+
+func TestHandleKeepsWrittenResponse(t *testing.T) {
+	handler := Handle(&config.ApiConfig{}, func(_ *config.ApiConfig, w http.ResponseWriter, _ *http.Request) error {
+		RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "ok"})
+		return errors.New("late failure")
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"message":"ok"}`, rec.Body.String())
+}
+
+func TestHandleWritesFieldErrors(t *testing.T) {
+	handler := MiddlewareRequestID(Handle(&config.ApiConfig{}, func(*config.ApiConfig, http.ResponseWriter, *http.Request) error {
+		return FieldValidationError(
+			FieldError{Field: "email", Code: "invalid_format", Detail: "Invalid email format"},
+			FieldError{Field: "password", Code: "too_short", Detail: "password must be at least 8 characters"},
+		)
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/user/signup", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "The request contains invalid fields",
+		"instance": "/v1/user/signup",
+		"code": "validation_failed",
+		"request_id": "req-123",
+		"errors": [
+			{"field": "email", "code": "invalid_format", "detail": "Invalid email format"},
+			{"field": "password", "code": "too_short", "detail": "password must be at least 8 characters"}
+		]
+	}`, rec.Body.String())
+}
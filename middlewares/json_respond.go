@@ -2,37 +2,77 @@ package middlewares
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
-func RespondWithError(w http.ResponseWriter, status int, msg string) {
-	if status > 499 {
-		log.Printf("responding with 5XX error: %s", msg)
-	}
+// Problem is an RFC 7807 problem details body. Code is the stable value
+// clients should branch on; Detail is for people and may change.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
 
-	type errorResponse struct {
-		Error string `json:"error"`
-	}
+// FieldError describes one invalid field of a request body.
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
 
-	RespondWithJSON(w, status, errorResponse{
-		Error: msg,
+func RespondWithError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	RespondWithProblem(w, r, Problem{
+		Status: status,
+		Code:   code,
+		Detail: detail,
 	})
 }
 
+// RespondWithProblem fills in the fields every problem shares (type, title,
+// instance and request ID) and writes it as application/problem+json.
+func RespondWithProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+	if problem.RequestID == "" {
+		problem.RequestID = RequestID(r.Context())
+	}
+
+	writeJSON(w, problem.Status, "application/problem+json", problem)
+}
+
 func RespondWithJSON(w http.ResponseWriter, status int, payload any) {
-	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, status, "application/json", payload)
+}
+
+func writeJSON(w http.ResponseWriter, status int, contentType string, payload any) {
+	w.Header().Set("Content-Type", contentType)
 
 	data, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("error marshaling JSON: %s", err)
-		http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-		w.WriteHeader(500)
+		slog.Error("couldn't marshal JSON response", "error", err)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusInternalServerError)
+		if _, err := w.Write([]byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"code":"internal_error"}`)); err != nil {
+			slog.Warn("couldn't write response", "error", err)
+		}
 		return
 	}
 
 	w.WriteHeader(status)
 	if _, err := w.Write(data); err != nil {
-		log.Printf("failed to write response: %v", err)
+		slog.Warn("couldn't write response", "error", err)
 	}
 }
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/metrics"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// MiddlewareMetrics records the count and latency of every request under
+// its chi route pattern.
+func MiddlewareMetrics(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.ObserveRequest(r.Method, routePattern(r), status, time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/STaninnat/booking-backend/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareMetricsUsesRoutePattern(t *testing.T) {
+	m := metrics.New()
+
+	router := chi.NewRouter()
+	router.Use(MiddlewareMetrics(m))
+	router.Get("/v1/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, id := range []string{"1", "2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/rooms/"+id, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `booking_http_requests_total{method="GET",route="/v1/rooms/{id}",status_class="2xx"} 2`)
+}
@@ -1,51 +1,193 @@
 package middlewares
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/STaninnat/booking-backend/internal/config"
 	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/internal/logging"
 	"github.com/STaninnat/booking-backend/security"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-type authhandler func(*config.ApiConfig, http.ResponseWriter, *http.Request, database.User)
+const (
+	AuthReasonTokenMissing   = "token_missing"
+	AuthReasonTokenExpired   = "token_expired"
+	AuthReasonTokenInvalid   = "token_invalid"
+	AuthReasonSessionRevoked = "session_revoked"
+
+	AuthReasonTwoFactorEnrollmentRequired = "two_factor_enrollment_required"
+)
+
+var errAuthLookup = errors.New("couldn't look up authenticated user")
+
+type authhandler func(*config.ApiConfig, http.ResponseWriter, *http.Request, database.User) error
 
 func MiddlewareAuth(cfg *config.ApiConfig, handler authhandler) http.HandlerFunc {
+	return middlewareAuth(cfg, handler, true)
+}
+
+// MiddlewareAuthAllowTwoFactorSetup authenticates without enforcing the
+// role's two-factor policy. It's for the endpoints a user needs in order to
+// comply with that policy: enrolment, confirmation and signing out.
+func MiddlewareAuthAllowTwoFactorSetup(cfg *config.ApiConfig, handler authhandler) http.HandlerFunc {
+	return middlewareAuth(cfg, handler, false)
+}
+
+func middlewareAuth(cfg *config.ApiConfig, handler authhandler, enforceTwoFactor bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tokenString, err := r.Cookie("access_token")
+		user, reason, err := authenticate(cfg, r)
 		if err != nil {
-			log.Println("Couldn't find token error: ", err)
+			if errors.Is(err, errAuthLookup) {
+				logging.FromContext(r.Context()).Error("couldn't get user", "error", err)
+				RespondWithError(w, r, http.StatusInternalServerError, "internal_error", "Couldn't verify session")
+				return
+			}
+
+			logging.FromContext(r.Context()).Info("authentication failed", "reason", reason, "error", err)
+			RespondWithAuthError(w, r, reason)
 			return
 		}
 
-		claims, err := security.ValidateJWTToken(tokenString.Value, cfg.JWTSecret)
-		if err != nil {
-			if err == jwt.ErrTokenExpired {
-				log.Println("Token expired error: ", err)
+		if enforceTwoFactor && !user.TotpEnabledAt.Valid {
+			required, err := RoleRequiresTwoFactor(r.Context(), cfg, user.Role)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("couldn't get role policy", "error", err)
+				RespondWithError(w, r, http.StatusInternalServerError, "internal_error", "Couldn't verify session")
+				return
+			}
+			if required {
+				RespondWithError(w, r, http.StatusForbidden, AuthReasonTwoFactorEnrollmentRequired,
+					"Two-factor authentication must be enabled for this account")
 				return
 			}
-			log.Printf("Token validation error: %v\n", err)
-			return
 		}
 
-		user, err := cfg.DB.GetUserByID(r.Context(), claims.UserID.String())
-		if err != nil {
-			log.Println("Couldn't get user error: ", err)
-			return
+		tw := &trackingWriter{ResponseWriter: w}
+		finishHandler(tw, r, handler(cfg, tw, r, user))
+	}
+}
+
+// MiddlewareRole authenticates the request like MiddlewareAuth and then
+// only lets users holding one of roles through.
+func MiddlewareRole(cfg *config.ApiConfig, handler authhandler, roles ...string) http.HandlerFunc {
+	return MiddlewareAuth(cfg, func(cfg *config.ApiConfig, w http.ResponseWriter, r *http.Request, user database.User) error {
+		if !slices.Contains(roles, user.Role) {
+			return ForbiddenError("insufficient_permissions", "Insufficient permissions")
 		}
 
-		if isAPIKeyExpired(user) {
-			log.Println("Api key expired error: ", err)
-			return
+		return handler(cfg, w, r, user)
+	})
+}
+
+// RespondWithAuthError writes a 401 whose code is the reason, so clients
+// know whether calling /user/refresh-key is worth a try.
+func RespondWithAuthError(w http.ResponseWriter, r *http.Request, reason string) {
+	setAuthChallenge(w, reason)
+	RespondWithError(w, r, http.StatusUnauthorized, reason, authReasonMessage(reason))
+}
+
+func setAuthChallenge(w http.ResponseWriter, reason string) {
+	challenge := `Bearer realm="booking-api"`
+	if reason != AuthReasonTokenMissing {
+		challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, reason)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+}
+
+// RoleRequiresTwoFactor reports whether an admin has made TOTP mandatory for
+// role. Roles without a stored policy don't require it.
+func RoleRequiresTwoFactor(ctx context.Context, cfg *config.ApiConfig, role string) (bool, error) {
+	policy, err := cfg.DB.GetRolePolicy(ctx, role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return policy.RequireTotp, nil
+}
+
+func authenticate(cfg *config.ApiConfig, r *http.Request) (database.User, string, error) {
+	accessToken, ok := accessTokenFromRequest(r)
+	if !ok {
+		return database.User{}, AuthReasonTokenMissing, errors.New("access token not found")
+	}
+
+	claims, err := security.ValidateJWTToken(accessToken, cfg.JWTKeys, cfg.Token)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return database.User{}, AuthReasonTokenExpired, err
 		}
+		return database.User{}, AuthReasonTokenInvalid, err
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), claims.UserID.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, AuthReasonSessionRevoked, errors.New("user no longer exists")
+		}
+		return database.User{}, "", fmt.Errorf("%w: %v", errAuthLookup, err)
+	}
 
-		handler(cfg, w, r, user)
+	if user.DeletedAt.Valid {
+		return database.User{}, AuthReasonSessionRevoked, errors.New("user has been deleted")
+	}
+
+	if isAPIKeyExpired(user) {
+		return database.User{}, AuthReasonSessionRevoked, errors.New("api key expired")
+	}
+
+	if isTokenRevoked(user, claims) {
+		return database.User{}, AuthReasonSessionRevoked, errors.New("token issued before sessions were revoked")
+	}
+
+	return user, "", nil
+}
+
+// accessTokenFromRequest takes the token from an Authorization: Bearer
+// header when there is one, and only otherwise from the access_token cookie,
+// so a request exempted from the CSRF check never authenticates by cookie.
+func accessTokenFromRequest(r *http.Request) (string, bool) {
+	if r.Header.Get("Authorization") != "" {
+		return bearerToken(r)
+	}
+
+	tokenCookie, err := r.Cookie("access_token")
+	if err != nil || tokenCookie.Value == "" {
+		return "", false
+	}
+	return tokenCookie.Value, true
+}
+
+func authReasonMessage(reason string) string {
+	switch reason {
+	case AuthReasonTokenMissing:
+		return "Authentication required"
+	case AuthReasonTokenExpired:
+		return "Access token expired"
+	case AuthReasonSessionRevoked:
+		return "Session has been revoked"
+	default:
+		return "Invalid access token"
 	}
 }
 
 func isAPIKeyExpired(user database.User) bool {
 	return user.ApiKeyExpiresAt.Before(time.Now().Local())
 }
+
+func isTokenRevoked(user database.User, claims *security.Claims) bool {
+	if !user.TokensInvalidBefore.Valid {
+		return false
+	}
+	// The cut-off is written in UTC and lib/pq reads the column back as
+	// UTC, so both sides are compared in UTC whatever the server's zone.
+	return claims.IssuedAt == nil || claims.IssuedAt.UTC().Before(user.TokensInvalidBefore.Time.UTC())
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/database"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareAuthRejections(t *testing.T) {
+	keys, err := security.NewHMACKeyRing(security.LegacyHMACKeyID, "test-secret")
+	assert.NoError(t, err)
+	cfg := &config.ApiConfig{
+		JWTKeys: keys,
+		Token:   security.TokenSettings{Issuer: "booking-api", Audience: "booking-frontend"},
+	}
+
+	expiredToken, err := security.GenerateJWTToken(uuid.New(), cfg.JWTKeys, cfg.Token, time.Now().Add(-1*time.Hour))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		cookie         *http.Cookie
+		authorization  string
+		expectedReason string
+	}{
+		{"missing cookie", nil, "", AuthReasonTokenMissing},
+		{"malformed token", &http.Cookie{Name: "access_token", Value: "invalid.token.here"}, "", AuthReasonTokenInvalid},
+		{"expired token", &http.Cookie{Name: "access_token", Value: expiredToken}, "", AuthReasonTokenExpired},
+		{"expired bearer token", nil, "Bearer " + expiredToken, AuthReasonTokenExpired},
+		{"non-bearer authorization", nil, "Basic dXNlcjpwYXNz", AuthReasonTokenMissing},
+		// A bearer header wins over the cookie, so CSRF-exempt requests
+		// never fall back to cookie authentication.
+		{"bearer header ignores cookie", &http.Cookie{Name: "access_token", Value: expiredToken}, "Bearer invalid.token.here", AuthReasonTokenInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := MiddlewareAuth(cfg, func(*config.ApiConfig, http.ResponseWriter, *http.Request, database.User) error {
+				called = true
+				return nil
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/bookings", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.False(t, called)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.True(t, strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Bearer"))
+
+			var problem Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+			assert.Equal(t, tt.expectedReason, problem.Code)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/STaninnat/booking-backend/security"
+)
+
+// RateLimitKeyFunc picks the bucket a request is counted against.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitPolicy is a token bucket of Limit requests refilled evenly over
+// Period. Name keeps the buckets of different route groups apart.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+	Key    RateLimitKeyFunc
+}
+
+// RateLimitByIP counts requests per client address.
+func RateLimitByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// RateLimitByUser counts requests per signed-in user and falls back to the
+// client address for anonymous requests. Only the token signature is
+// checked here; the auth middleware still does the full session check.
+func RateLimitByUser(cfg *config.ApiConfig) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if accessToken, ok := accessTokenFromRequest(r); ok {
+			if claims, err := security.ValidateJWTToken(accessToken, cfg.JWTKeys, cfg.Token); err == nil {
+				return "user:" + claims.UserID.String()
+			}
+		}
+		return RateLimitByIP(r)
+	}
+}
+
+// MiddlewareRateLimit enforces policy using store and reports the bucket
+// state in the RateLimit-* headers. If the store fails the request is let
+// through: an outage of a shared store shouldn't take the API down with it.
+func MiddlewareRateLimit(store RateLimitStore, policy RateLimitPolicy) func(http.Handler) http.Handler {
+	key := policy.Key
+	if key == nil {
+		key = RateLimitByIP
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := store.Take(r.Context(), policy.Name+":"+key(r), policy.Limit, policy.Period)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("rate limit store failed", "policy", policy.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", policyHeader)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				RespondWithError(w, r, http.StatusTooManyRequests, "rate_limited", "Too many requests, please try again later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
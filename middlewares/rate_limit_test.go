@@ -0,0 +1,131 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/security"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := range 3 {
+		result, err := store.Take(ctx, "key", 3, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result, err := store.Take(ctx, "key", 3, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 20*time.Second, result.RetryAfter)
+	assert.Equal(t, time.Minute, result.Reset)
+
+	// Other keys have their own bucket.
+	result, err = store.Take(ctx, "other", 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// One token comes back every 20 seconds.
+	now = now.Add(20 * time.Second)
+	result, err = store.Take(ctx, "key", 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	handler := MiddlewareRateLimit(store, RateLimitPolicy{Name: "auth", Limit: 2, Period: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	)
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/user/signin", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("192.0.2.1:1234")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+
+	assert.Equal(t, http.StatusNoContent, send("192.0.2.1:5678").Code)
+
+	rec = send("192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Too Many Requests",
+		"status": 429,
+		"detail": "Too many requests, please try again later",
+		"instance": "/v1/user/signin",
+		"code": "rate_limited"
+	}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusNoContent, send("192.0.2.2:1234").Code)
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, int, time.Duration) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func TestMiddlewareRateLimitFailsOpen(t *testing.T) {
+	handler := MiddlewareRateLimit(failingRateLimitStore{}, RateLimitPolicy{Name: "default", Limit: 1, Period: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/rooms", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	keys, err := security.NewHMACKeyRing(security.LegacyHMACKeyID, "test-secret")
+	require.NoError(t, err)
+	cfg := &config.ApiConfig{
+		JWTKeys: keys,
+		Token:   security.TokenSettings{Issuer: "booking-api", Audience: "booking-frontend"},
+	}
+
+	userID := uuid.New()
+	token, err := security.GenerateJWTToken(userID, cfg.JWTKeys, cfg.Token, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	keyFunc := RateLimitByUser(cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/bookings", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	assert.Equal(t, "user:"+userID.String(), keyFunc(req))
+
+	anonymous := httptest.NewRequest(http.MethodPost, "/v1/bookings", nil)
+	anonymous.RemoteAddr = "192.0.2.1:1234"
+	anonymous.AddCookie(&http.Cookie{Name: "access_token", Value: "forged"})
+	assert.Equal(t, "ip:192.0.2.1", keyFunc(anonymous))
+}
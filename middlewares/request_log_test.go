@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/STaninnat/booking-backend/internal/logging"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	var seen string
+	handler := MiddlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{"reuses valid id", "req-123", true},
+		{"generates when missing", "", false},
+		{"replaces unsafe id", "bad id\nwith newline", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+			if tt.reused {
+				assert.Equal(t, tt.incoming, seen)
+			} else {
+				assert.NotEqual(t, tt.incoming, seen)
+			}
+		})
+	}
+}
+
+func TestMiddlewareLoggerWritesAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(MiddlewareRequestID)
+	router.Use(MiddlewareLogger(logger))
+	router.Get("/v1/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/rooms/42?code=secret", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var handlerLine map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &handlerLine))
+	assert.Equal(t, "req-123", handlerLine["request_id"])
+
+	var accessLine map[string]any
+	require.NoError(t, json.Unmarshal(lines[1], &accessLine))
+	assert.Equal(t, "request", accessLine["msg"])
+	assert.Equal(t, "req-123", accessLine["request_id"])
+	assert.Equal(t, "/v1/rooms/42", accessLine["path"])
+	assert.Equal(t, "/v1/rooms/{id}", accessLine["route"])
+	assert.Equal(t, float64(http.StatusTeapot), accessLine["status"])
+	assert.NotContains(t, string(lines[1]), "secret")
+}
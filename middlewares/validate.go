@@ -0,0 +1,209 @@
+package middlewares
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/STaninnat/booking-backend/security"
+)
+
+// DateLayout is the format of date-only fields such as check_in.
+const DateLayout = "2006-01-02"
+
+// Validate checks the `validate` tags on the fields of the struct v points
+// to and returns every failure, named by the field's JSON name. Rules are
+// comma separated:
+//
+//	required     non-nil pointer, non-blank string, non-zero number unless
+//	             sent through a pointer
+//	notblank     a pointer field may be omitted but not sent empty
+//	min=N, max=N rune length for strings, value for numbers (zero included)
+//	email        valid email address
+//	username     valid username
+//	date         YYYY-MM-DD date
+//	after=field  date strictly after the date in the named JSON field
+//	oneof=a b c  one of the space separated values
+//
+// Apart from required, rules skip nil pointers and blank strings, so
+// optional fields are only checked when present. Numbers are always
+// checked: a number that may be zero and is also required, such as a free
+// room's price, has to be a pointer so that zero isn't taken as missing.
+func Validate(v any) []FieldError {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("middlewares.Validate: want pointer to struct, got %T", v))
+	}
+	valueType := value.Type()
+
+	var fieldErrs []FieldError
+	for i := range valueType.NumField() {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		name := jsonFieldName(field)
+		if fieldErr, ok := validateField(value, name, value.Field(i), strings.Split(tag, ",")); !ok {
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+	}
+
+	return fieldErrs
+}
+
+// validateField stops at the first failing rule so each field reports at
+// most one error.
+func validateField(parent reflect.Value, name string, value reflect.Value, rules []string) (FieldError, bool) {
+	present := true
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if slices.Contains(rules, "required") {
+				return FieldError{Field: name, Code: "required", Detail: name + " is required"}, false
+			}
+			return FieldError{}, true
+		}
+		value = value.Elem()
+	} else {
+		present = false
+	}
+
+	// Strings are empty when blank. Other values sent through a pointer are
+	// present even when zero, and numbers are checked whatever their value.
+	if value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "" ||
+		value.Kind() != reflect.String && !present && value.IsZero() {
+		if slices.Contains(rules, "required") {
+			return FieldError{Field: name, Code: "required", Detail: name + " is required"}, false
+		}
+		if present && slices.Contains(rules, "notblank") {
+			return FieldError{Field: name, Code: "blank", Detail: name + " must not be empty"}, false
+		}
+		if !isNumber(value.Kind()) {
+			return FieldError{}, true
+		}
+	}
+
+	for _, rule := range rules {
+		rule, arg, _ := strings.Cut(rule, "=")
+
+		var code, detail string
+		switch rule {
+		case "required", "notblank":
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("middlewares.Validate: invalid %s=%q on %s", rule, arg, name))
+			}
+			code, detail = checkBound(name, rule, value, limit, arg)
+		case "email":
+			if !security.IsValidateEmailFormat(value.String()) {
+				code, detail = "invalid_format", name+" must be a valid email address"
+			}
+		case "username":
+			if !security.IsValidUserNameFormat(value.String()) {
+				code, detail = "invalid_format", name+" is not a valid username"
+			}
+		case "date":
+			if _, err := time.Parse(DateLayout, value.String()); err != nil {
+				code, detail = "invalid_format", name+" must be a date in YYYY-MM-DD format"
+			}
+		case "after":
+			code, detail = checkAfter(parent, name, value, arg)
+		case "oneof":
+			if !slices.Contains(strings.Fields(arg), fmt.Sprint(value.Interface())) {
+				code, detail = "not_allowed", fmt.Sprintf("%s must be one of: %s", name, strings.Join(strings.Fields(arg), ", "))
+			}
+		default:
+			panic(fmt.Sprintf("middlewares.Validate: unknown rule %q on %s", rule, name))
+		}
+
+		if code != "" {
+			return FieldError{Field: name, Code: code, Detail: detail}, false
+		}
+	}
+
+	return FieldError{}, true
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func checkBound(name, rule string, value reflect.Value, limit float64, arg string) (string, string) {
+	var actual float64
+	isLength := false
+	switch value.Kind() {
+	case reflect.String:
+		actual, isLength = float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		panic(fmt.Sprintf("middlewares.Validate: %s not supported on %s", rule, name))
+	}
+
+	switch {
+	case rule == "min" && actual < limit && isLength:
+		return "too_short", fmt.Sprintf("%s must be at least %s characters", name, arg)
+	case rule == "min" && actual < limit:
+		return "too_small", fmt.Sprintf("%s must be at least %s", name, arg)
+	case rule == "max" && actual > limit && isLength:
+		return "too_long", fmt.Sprintf("%s must be at most %s characters", name, arg)
+	case rule == "max" && actual > limit:
+		return "too_large", fmt.Sprintf("%s must be at most %s", name, arg)
+	}
+	return "", ""
+}
+
+// checkAfter leaves unparsable dates to their own date rule.
+func checkAfter(parent reflect.Value, name string, value reflect.Value, other string) (string, string) {
+	otherValue, ok := fieldByJSONName(parent, other)
+	if !ok {
+		panic(fmt.Sprintf("middlewares.Validate: after=%s on %s names no field", other, name))
+	}
+
+	date, err := time.Parse(DateLayout, value.String())
+	if err != nil {
+		return "", ""
+	}
+	otherDate, err := time.Parse(DateLayout, reflect.Indirect(otherValue).String())
+	if err != nil {
+		return "", ""
+	}
+
+	if !date.After(otherDate) {
+		return "not_after", fmt.Sprintf("%s must be after %s", name, other)
+	}
+	return "", ""
+}
+
+func fieldByJSONName(parent reflect.Value, name string) (reflect.Value, bool) {
+	for i := range parent.NumField() {
+		if jsonFieldName(parent.Type().Field(i)) == name {
+			return parent.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	type booking struct {
+		CheckIn  string   `json:"check_in" validate:"required,date"`
+		CheckOut string   `json:"check_out" validate:"required,date,after=check_in"`
+		Email    string   `json:"email" validate:"email"`
+		Name     *string  `json:"name" validate:"notblank,max=5"`
+		Guests   *int32   `json:"guests" validate:"min=1,max=4"`
+		Kind     string   `json:"kind" validate:"oneof=single double"`
+		Price    *float64 `json:"price" validate:"required,min=0"`
+		Paid     *bool    `json:"paid" validate:"required"`
+	}
+
+	blank := "  "
+	long := "abcdef"
+	name := "Ann"
+	two, nine, none := int32(2), int32(9), int32(0)
+	price, free, negative := 120.5, 0.0, -1.0
+	unpaid := false
+
+	tests := []struct {
+		name  string
+		input booking
+		want  []FieldError
+	}{
+		{
+			name:  "valid",
+			input: booking{CheckIn: "2025-01-01", CheckOut: "2025-01-03", Email: "a@example.com", Name: &name, Guests: &two, Kind: "double", Price: &price, Paid: &unpaid},
+		},
+		{
+			name:  "optional fields omitted",
+			input: booking{CheckIn: "2025-01-01", CheckOut: "2025-01-02", Price: &price, Paid: &unpaid},
+		},
+		{
+			name:  "zero is a value, not a missing field",
+			input: booking{CheckIn: "2025-01-01", CheckOut: "2025-01-02", Guests: &none, Price: &free, Paid: &unpaid},
+			want: []FieldError{
+				{Field: "guests", Code: "too_small", Detail: "guests must be at least 1"},
+			},
+		},
+		{
+			name:  "every failure reported",
+			input: booking{CheckOut: "2025-13-01", Email: "nope", Name: &blank, Guests: &nine, Kind: "suite", Price: &negative},
+			want: []FieldError{
+				{Field: "check_in", Code: "required", Detail: "check_in is required"},
+				{Field: "check_out", Code: "invalid_format", Detail: "check_out must be a date in YYYY-MM-DD format"},
+				{Field: "email", Code: "invalid_format", Detail: "email must be a valid email address"},
+				{Field: "name", Code: "blank", Detail: "name must not be empty"},
+				{Field: "guests", Code: "too_large", Detail: "guests must be at most 4"},
+				{Field: "kind", Code: "not_allowed", Detail: "kind must be one of: single, double"},
+				{Field: "price", Code: "too_small", Detail: "price must be at least 0"},
+				{Field: "paid", Code: "required", Detail: "paid is required"},
+			},
+		},
+		{
+			name:  "date order and length",
+			input: booking{CheckIn: "2025-01-03", CheckOut: "2025-01-03", Name: &long, Price: &price, Paid: &unpaid},
+			want: []FieldError{
+				{Field: "check_out", Code: "not_after", Detail: "check_out must be after check_in"},
+				{Field: "name", Code: "too_long", Detail: "name must be at most 5 characters"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Validate(&tt.input))
+		})
+	}
+}
+
+func TestValidateChecksZeroNumbers(t *testing.T) {
+	type params struct {
+		MaxGuests int32   `json:"max_guests" validate:"required,min=1"`
+		Floor     int32   `json:"floor" validate:"min=1,max=20"`
+		Discount  float64 `json:"discount" validate:"min=0,max=50"`
+	}
+
+	assert.Equal(t, []FieldError{
+		{Field: "max_guests", Code: "required", Detail: "max_guests is required"},
+		{Field: "floor", Code: "too_small", Detail: "floor must be at least 1"},
+	}, Validate(&params{}))
+	assert.Empty(t, Validate(&params{MaxGuests: 2, Floor: 3}))
+}
+
+func TestValidatePanicsOnUnknownRule(t *testing.T) {
+	type params struct {
+		Name string `json:"name" validate:"shiny"`
+	}
+
+	assert.Panics(t, func() { Validate(&params{Name: "x"}) })
+}
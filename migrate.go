@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/STaninnat/booking-backend/internal/config"
+	"github.com/STaninnat/booking-backend/internal/migrations"
+)
+
+// runMigrate implements `booking migrate up|down|status`. cfg comes from
+// config.LoadMigrate, so only the database settings are set.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: booking migrate up|down|status")
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	provider, err := migrations.NewProvider(db)
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+
+	return migrations.Run(context.Background(), provider, args[0], os.Stdout)
+}
+
+// openDB opens the pool with the configured limits and checks that the
+// database is reachable.
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", string(cfg.DatabaseURL))
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	cfg.DBPool.Apply(db)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	return db, nil
+}
@@ -1,8 +1,5 @@
 #!/bin/bash
 
-if [ -f .env ]; then
-    source .env
-fi
-
-cd sql/schema
-goose postgres $DATABASE_URL up
\ No newline at end of file
+# The migrations are embedded in the binary built by buildprod.sh, so goose
+# doesn't need to be installed. The binary reads .env itself.
+./booking migrate up
\ No newline at end of file
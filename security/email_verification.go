@@ -0,0 +1,58 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type EmailVerificationClaims struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// emailVerificationAudience differs from the frontend audience so a
+// verification link can never be replayed as an access token.
+func emailVerificationAudience(settings TokenSettings) string {
+	return settings.Issuer + "/email-verification"
+}
+
+func GenerateEmailVerificationToken(userID uuid.UUID, email string, keys *KeyRing, settings TokenSettings, expiresAt time.Time) (string, error) {
+	claims := EmailVerificationClaims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    settings.Issuer,
+			Audience:  []string{emailVerificationAudience(settings)},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	return keys.Sign(claims)
+}
+
+func ValidateEmailVerificationToken(tokenString string, keys *KeyRing, settings TokenSettings) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc,
+		jwt.WithValidMethods(keys.Methods()),
+		jwt.WithIssuer(settings.Issuer),
+		jwt.WithAudience(emailVerificationAudience(settings)),
+		jwt.WithLeeway(settings.Leeway),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse verification token: %w", err)
+	}
+	if !token.Valid || claims.Email == "" {
+		return nil, errors.New("invalid verification token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,34 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerificationToken(t *testing.T) {
+	keys, err := NewHMACKeyRing(LegacyHMACKeyID, "test-secret")
+	require.NoError(t, err)
+	settings := TokenSettings{Issuer: "booking-api", Audience: "booking-frontend"}
+
+	userID := uuid.New()
+	token, err := GenerateEmailVerificationToken(userID, "guest@example.com", keys, settings, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	claims, err := ValidateEmailVerificationToken(token, keys, settings)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, "guest@example.com", claims.Email)
+
+	// A verification token must not pass as an access token and vice versa.
+	_, err = ValidateJWTToken(token, keys, settings)
+	assert.Error(t, err)
+
+	accessToken, err := GenerateJWTToken(userID, keys, settings, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = ValidateEmailVerificationToken(accessToken, keys, settings)
+	assert.Error(t, err)
+}
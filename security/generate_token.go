@@ -40,21 +40,26 @@ func GenerateRandomSHA256HASH() (string, error) {
 	return hashString, nil
 }
 
-func GenerateJWTToken(userID uuid.UUID, secret string, expiresAt time.Time) (string, error) {
+// HashToken returns the SHA-256 hex digest of a single-use token so only the
+// digest needs to be stored and can still be looked up directly.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func GenerateJWTToken(userID uuid.UUID, keys *KeyRing, settings TokenSettings, expiresAt time.Time) (string, error) {
 	claims := Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "my-api-service",
-			Audience:  []string{"my-frontend-app"},
+			Issuer:    settings.Issuer,
+			Audience:  []string{settings.Audience},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,321 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LegacyHMACKeyID is the kid given to the shared JWT_SECRET. Tokens issued
+// before key rotation carry no kid and are verified against this key.
+const LegacyHMACKeyID = "hs256"
+
+const minRSAKeyBits = 2048
+
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Retired   bool
+	signKey   any
+	verifyKey any
+}
+
+// KeyRing holds every key that may have signed a token still in circulation.
+// Only the active key signs; all non-retired keys verify.
+type KeyRing struct {
+	keys     map[string]*SigningKey
+	activeID string
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*SigningKey)}
+}
+
+// NewHMACKeyRing builds a single-key ring, which is all the refresh tokens
+// and the legacy configuration need.
+func NewHMACKeyRing(id, secret string) (*KeyRing, error) {
+	ring := NewKeyRing()
+	if err := ring.AddHMACKey(id, []byte(secret)); err != nil {
+		return nil, err
+	}
+	if err := ring.SetActive(id); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// LoadKeyRing reads every *.pem private key in dir, using the file name
+// without its extension as the kid. A non-empty hmacSecret is added under
+// LegacyHMACKeyID so tokens signed before the switch keep verifying until
+// that kid is retired.
+func LoadKeyRing(dir, activeID string, retiredIDs []string, hmacSecret string) (*KeyRing, error) {
+	ring := NewKeyRing()
+
+	if hmacSecret != "" {
+		if err := ring.AddHMACKey(LegacyHMACKeyID, []byte(hmacSecret)); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't list keys in %s: %w", dir, err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+			if err != nil {
+				return nil, fmt.Errorf("couldn't read key %s: %w", path, err)
+			}
+			id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			if err := ring.AddPrivateKeyPEM(id, data); err != nil {
+				return nil, fmt.Errorf("couldn't load key %s: %w", path, err)
+			}
+		}
+	}
+
+	if len(ring.keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+
+	for _, id := range retiredIDs {
+		if key, ok := ring.keys[id]; ok {
+			key.Retired = true
+		}
+	}
+
+	if activeID == "" {
+		if len(ring.keys) != 1 {
+			return nil, errors.New("an active key id is required when more than one key is configured")
+		}
+		for id := range ring.keys {
+			activeID = id
+		}
+	}
+
+	if err := ring.SetActive(activeID); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+func (k *KeyRing) AddHMACKey(id string, secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("key %q: empty secret", id)
+	}
+	return k.add(&SigningKey{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret})
+}
+
+func (k *KeyRing) AddPrivateKey(id string, key any) error {
+	switch priv := key.(type) {
+	case *rsa.PrivateKey:
+		if priv.N.BitLen() < minRSAKeyBits {
+			return fmt.Errorf("key %q: RSA keys must be at least %d bits", id, minRSAKeyBits)
+		}
+		return k.add(&SigningKey{ID: id, Method: jwt.SigningMethodRS256, signKey: priv, verifyKey: &priv.PublicKey})
+	case ed25519.PrivateKey:
+		return k.add(&SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, signKey: priv, verifyKey: priv.Public()})
+	default:
+		return fmt.Errorf("key %q: unsupported key type %T", id, key)
+	}
+}
+
+func (k *KeyRing) AddPrivateKeyPEM(id string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("key %q: no PEM block found", id)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", id, err)
+		}
+		return k.AddPrivateKey(id, priv)
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", id, err)
+		}
+		return k.AddPrivateKey(id, priv)
+	default:
+		return fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+}
+
+func (k *KeyRing) SetActive(id string) error {
+	key, ok := k.keys[id]
+	if !ok {
+		return fmt.Errorf("active key %q not found", id)
+	}
+	if key.Retired {
+		return fmt.Errorf("active key %q is retired", id)
+	}
+	k.activeID = id
+	return nil
+}
+
+func (k *KeyRing) ActiveID() string {
+	return k.activeID
+}
+
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	key, ok := k.keys[k.activeID]
+	if !ok {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.signKey)
+}
+
+// Keyfunc resolves the verification key from the token's kid and refuses
+// tokens whose alg doesn't match the key, so an RSA public key can never be
+// used as an HMAC secret.
+func (k *KeyRing) Keyfunc(token *jwt.Token) (any, error) {
+	id, _ := token.Header["kid"].(string)
+	if id == "" {
+		id = LegacyHMACKeyID
+	}
+
+	key, ok := k.keys[id]
+	if !ok || key.Retired {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), id)
+	}
+
+	return key.verifyKey, nil
+}
+
+func (k *KeyRing) Methods() []string {
+	var methods []string
+	for _, key := range k.keys {
+		if !slices.Contains(methods, key.Method.Alg()) {
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// JWKS publishes the public half of every non-retired asymmetric key.
+// HMAC secrets are never exposed.
+func (k *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := k.keys[id]
+		if key.Retired {
+			continue
+		}
+
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return set
+}
+
+// PublicKey decodes a published JWK back into a verification key. It
+// understands the same key types JWKS publishes.
+func (j JWK) PublicKey() (any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus: %w", j.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid exponent: %w", j.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %q: exponent too large", j.Kid)
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("key %q: RSA keys must be at least %d bits", j.Kid, minRSAKeyBits)
+		}
+		return pub, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("key %q: unsupported curve %q", j.Kid, j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q: invalid Ed25519 public key", j.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %q", j.Kid, j.Kty)
+	}
+}
+
+func (k *KeyRing) add(key *SigningKey) error {
+	if key.ID == "" {
+		return errors.New("key id must not be empty")
+	}
+	if _, exists := k.keys[key.ID]; exists {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	k.keys[key.ID] = key
+	return nil
+}
@@ -0,0 +1,99 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyRing(t *testing.T) *KeyRing {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ring := NewKeyRing()
+	require.NoError(t, ring.AddHMACKey(LegacyHMACKeyID, []byte("legacy-secret")))
+	require.NoError(t, ring.AddPrivateKey("rsa-1", rsaKey))
+	require.NoError(t, ring.AddPrivateKey("ed-1", edKey))
+	return ring
+}
+
+func TestKeyRingSignAndVerify(t *testing.T) {
+	ring := newTestKeyRing(t)
+	claims := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	for _, id := range []string{LegacyHMACKeyID, "rsa-1", "ed-1"} {
+		t.Run(id, func(t *testing.T) {
+			require.NoError(t, ring.SetActive(id))
+
+			tokenString, err := ring.Sign(claims)
+			require.NoError(t, err)
+
+			token, err := jwt.Parse(tokenString, ring.Keyfunc)
+			require.NoError(t, err)
+			assert.Equal(t, id, token.Header["kid"])
+		})
+	}
+}
+
+func TestKeyRingRejectsRetiredKeys(t *testing.T) {
+	ring := newTestKeyRing(t)
+	require.NoError(t, ring.SetActive("rsa-1"))
+
+	tokenString, err := ring.Sign(jwt.RegisteredClaims{})
+	require.NoError(t, err)
+
+	ring.keys["rsa-1"].Retired = true
+
+	_, err = jwt.Parse(tokenString, ring.Keyfunc)
+	assert.Error(t, err)
+	assert.Error(t, ring.SetActive("rsa-1"))
+}
+
+func TestKeyRingRejectsAlgorithmMismatch(t *testing.T) {
+	ring := newTestKeyRing(t)
+
+	// An HS256 token claiming the RSA kid must not verify.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{})
+	token.Header["kid"] = "rsa-1"
+	tokenString, err := token.SignedString([]byte("legacy-secret"))
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(tokenString, ring.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeyRingJWKS(t *testing.T) {
+	ring := newTestKeyRing(t)
+	ring.keys["ed-1"].Retired = true
+
+	set := ring.JWKS()
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "rsa-1", set.Keys[0].Kid)
+	assert.Equal(t, "RSA", set.Keys[0].Kty)
+	assert.Equal(t, "RS256", set.Keys[0].Alg)
+	assert.NotEmpty(t, set.Keys[0].N)
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+}
+
+func TestJWKPublicKeyRoundTrip(t *testing.T) {
+	ring := newTestKeyRing(t)
+
+	for _, jwk := range ring.JWKS().Keys {
+		pub, err := jwk.PublicKey()
+		require.NoError(t, err)
+		assert.Equal(t, ring.keys[jwk.Kid].verifyKey, pub)
+	}
+
+	_, err := JWK{Kty: "oct", Kid: "secret"}.PublicKey()
+	assert.Error(t, err)
+}
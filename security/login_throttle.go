@@ -0,0 +1,105 @@
+package security
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginThrottle slows down repeated failed sign-ins from the same key
+// (usually the client IP). The first freeAttempts failures cost nothing;
+// after that each attempt has to wait twice as long as the previous one,
+// up to maxDelay. State is forgotten after window without failures.
+type LoginThrottle struct {
+	mu           sync.Mutex
+	attempts     map[string]*throttleEntry
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	window       time.Duration
+	now          func() time.Time
+}
+
+type throttleEntry struct {
+	failures    int
+	lastFailure time.Time
+}
+
+func NewLoginThrottle(freeAttempts int, baseDelay, maxDelay, window time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		attempts:     make(map[string]*throttleEntry),
+		freeAttempts: freeAttempts,
+		baseDelay:    baseDelay,
+		maxDelay:     maxDelay,
+		window:       window,
+		now:          time.Now,
+	}
+}
+
+// Check returns how long the caller must wait before another attempt is
+// allowed, or zero when it may proceed.
+func (t *LoginThrottle) Check(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.attempts[key]
+	if !ok {
+		return 0
+	}
+
+	now := t.now()
+	if now.Sub(entry.lastFailure) > t.window {
+		delete(t.attempts, key)
+		return 0
+	}
+
+	wait := entry.lastFailure.Add(t.delay(entry.failures)).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+func (t *LoginThrottle) Failure(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	entry, ok := t.attempts[key]
+	if !ok || now.Sub(entry.lastFailure) > t.window {
+		entry = &throttleEntry{}
+		t.attempts[key] = entry
+	}
+	entry.failures++
+	entry.lastFailure = now
+
+	t.sweep(now)
+}
+
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	excess := failures - t.freeAttempts
+	if excess <= 0 {
+		return 0
+	}
+
+	delay := t.baseDelay
+	for i := 1; i < excess && delay < t.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+	return delay
+}
+
+// sweep drops stale entries once the map grows, keeping memory bounded
+// without a background goroutine.
+func (t *LoginThrottle) sweep(now time.Time) {
+	if len(t.attempts) < 1024 {
+		return
+	}
+	for key, entry := range t.attempts {
+		if now.Sub(entry.lastFailure) > t.window {
+			delete(t.attempts, key)
+		}
+	}
+}
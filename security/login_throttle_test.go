@@ -0,0 +1,37 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottleProgressiveDelay(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := NewLoginThrottle(3, time.Second, 8*time.Second, time.Hour)
+	throttle.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, throttle.Check("10.0.0.1"))
+		throttle.Failure("10.0.0.1")
+	}
+	assert.Zero(t, throttle.Check("10.0.0.1"))
+
+	throttle.Failure("10.0.0.1")
+	assert.Equal(t, time.Second, throttle.Check("10.0.0.1"))
+
+	throttle.Failure("10.0.0.1")
+	assert.Equal(t, 2*time.Second, throttle.Check("10.0.0.1"))
+
+	for i := 0; i < 5; i++ {
+		throttle.Failure("10.0.0.1")
+	}
+	assert.Equal(t, 8*time.Second, throttle.Check("10.0.0.1"))
+
+	// Other clients are unaffected.
+	assert.Zero(t, throttle.Check("10.0.0.2"))
+
+	now = now.Add(2 * time.Hour)
+	assert.Zero(t, throttle.Check("10.0.0.1"))
+}
@@ -0,0 +1,98 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{HashAlgorithmBcrypt, HashAlgorithmArgon2id} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := NewPasswordHasher(algorithm, bcrypt.MinCost, testArgon2Params)
+			require.NoError(t, err)
+
+			hashed, err := hasher.Hash("correct horse battery")
+			require.NoError(t, err)
+
+			match, needsRehash, err := hasher.Verify("correct horse battery", hashed)
+			require.NoError(t, err)
+			assert.True(t, match)
+			assert.False(t, needsRehash)
+
+			match, _, err = hasher.Verify("wrong password", hashed)
+			require.NoError(t, err)
+			assert.False(t, match)
+		})
+	}
+}
+
+func TestPasswordHasherDetectsOutdatedHashes(t *testing.T) {
+	oldBcrypt, err := NewPasswordHasher(HashAlgorithmBcrypt, bcrypt.MinCost, testArgon2Params)
+	require.NoError(t, err)
+	legacyHash, err := oldBcrypt.Hash("correct horse battery")
+	require.NoError(t, err)
+
+	strongerBcrypt, err := NewPasswordHasher(HashAlgorithmBcrypt, bcrypt.MinCost+1, testArgon2Params)
+	require.NoError(t, err)
+	match, needsRehash, err := strongerBcrypt.Verify("correct horse battery", legacyHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+
+	argon, err := NewPasswordHasher(HashAlgorithmArgon2id, bcrypt.MinCost, testArgon2Params)
+	require.NoError(t, err)
+	match, needsRehash, err = argon.Verify("correct horse battery", legacyHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+
+	argonHash, err := argon.Hash("correct horse battery")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	moreIterations := testArgon2Params
+	moreIterations.Iterations = 2
+	strongerArgon, err := NewPasswordHasher(HashAlgorithmArgon2id, bcrypt.MinCost, moreIterations)
+	require.NoError(t, err)
+	match, needsRehash, err = strongerArgon.Verify("correct horse battery", argonHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+}
+
+func TestPasswordHasherKeepsBcryptHashForOverlongPasswords(t *testing.T) {
+	legacy, err := NewPasswordHasher(HashAlgorithmBcrypt, bcrypt.MinCost, testArgon2Params)
+	require.NoError(t, err)
+	password := strings.Repeat("p", BcryptMaxPasswordBytes)
+	legacyHash, err := legacy.Hash(password)
+	require.NoError(t, err)
+
+	argon, err := NewPasswordHasher(HashAlgorithmArgon2id, bcrypt.MinCost, testArgon2Params)
+	require.NoError(t, err)
+
+	match, needsRehash, err := argon.Verify(password, legacyHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+
+	// bcrypt only compares the first 72 bytes, so the extra characters
+	// match too, but the hash must not be rebuilt from them.
+	match, needsRehash, err = argon.Verify(password+"-extra", legacyHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+}
+
+func TestPasswordHasherRejectsUnknownFormat(t *testing.T) {
+	hasher, err := NewPasswordHasher(HashAlgorithmBcrypt, bcrypt.MinCost, testArgon2Params)
+	require.NoError(t, err)
+
+	_, _, err = hasher.Verify("password", "plaintext")
+	assert.ErrorIs(t, err, ErrUnknownHashFormat)
+}
@@ -0,0 +1,102 @@
+package security
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// BcryptMaxPasswordBytes is where bcrypt silently stops reading input.
+// Anything past it would be ignored, so the policy never allows more.
+const BcryptMaxPasswordBytes = 72
+
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	blocked   map[string]struct{}
+}
+
+func NewPasswordPolicy(minLength, maxLength int, blocked []string) (*PasswordPolicy, error) {
+	if minLength < 1 {
+		return nil, errors.New("minimum password length must be at least 1")
+	}
+	if maxLength > BcryptMaxPasswordBytes {
+		return nil, fmt.Errorf("maximum password length can't exceed %d bytes", BcryptMaxPasswordBytes)
+	}
+	if maxLength < minLength {
+		return nil, errors.New("maximum password length must not be below the minimum")
+	}
+
+	policy := &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		blocked:   make(map[string]struct{}, len(blocked)),
+	}
+	for _, password := range blocked {
+		policy.blocked[strings.ToLower(password)] = struct{}{}
+	}
+
+	return policy, nil
+}
+
+// LoadPasswordBlocklist reads one password per line, skipping blank lines
+// and lines starting with '#'.
+func LoadPasswordBlocklist(path string) ([]string, error) {
+	file, err := os.Open(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open password blocklist: %w", err)
+	}
+	defer file.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords = append(passwords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read password blocklist: %w", err)
+	}
+
+	return passwords, nil
+}
+
+// PasswordPolicyError says why a password was rejected. Code is stable for
+// clients to branch on; the message is meant to be shown to the user.
+type PasswordPolicyError struct {
+	Code    string
+	Message string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Message
+}
+
+// Validate returns a *PasswordPolicyError when the password is rejected.
+// userInputs (username, email, ...) may not be used as the password either.
+func (p *PasswordPolicy) Validate(password string, userInputs ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &PasswordPolicyError{"too_short", fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+	if len(password) > p.MaxLength {
+		return &PasswordPolicyError{"too_long", fmt.Sprintf("password must be at most %d bytes", p.MaxLength)}
+	}
+
+	lowered := strings.ToLower(password)
+	if _, ok := p.blocked[lowered]; ok {
+		return &PasswordPolicyError{"too_common", "password is too common, please choose a different one"}
+	}
+	for _, input := range userInputs {
+		if input != "" && lowered == strings.ToLower(input) {
+			return &PasswordPolicyError{"matches_user_input", "password must not match your username or email"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy, err := NewPasswordPolicy(8, BcryptMaxPasswordBytes, []string{"Password123"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+		wantCode string
+	}{
+		{"valid", "correct horse battery", "", ""},
+		{"too short", "short", "at least 8", "too_short"},
+		{"too long for bcrypt", strings.Repeat("a", 73), "at most 72", "too_long"},
+		{"blocked case-insensitively", "password123", "too common", "too_common"},
+		{"matches username", "guest_user", "username or email", "matches_user_input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "Guest_User", "guest@example.com")
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+
+			var policyErr *PasswordPolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, tt.wantCode, policyErr.Code)
+		})
+	}
+}
+
+func TestNewPasswordPolicyRejectsMaxAboveBcryptLimit(t *testing.T) {
+	_, err := NewPasswordPolicy(8, 100, nil)
+	assert.Error(t, err)
+}
+
+func TestLoadPasswordBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# comment\nqwerty123\n\n  letmein1  \n"), 0o600))
+
+	passwords, err := LoadPasswordBlocklist(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qwerty123", "letmein1"}, passwords)
+}
@@ -0,0 +1,41 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReauthToken(t *testing.T) {
+	keys, err := NewHMACKeyRing(LegacyHMACKeyID, "test-secret")
+	require.NoError(t, err)
+	settings := TokenSettings{Issuer: "booking-api", Audience: "booking-frontend"}
+
+	userID := uuid.New()
+	token, err := GenerateReauthToken(userID, keys, settings, time.Now().Add(5*time.Minute))
+	require.NoError(t, err)
+
+	claims, err := ValidateReauthToken(token, keys, settings)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	// Neither an access token nor an mfa_token can stand in for it, and it
+	// can't stand in for them.
+	_, err = ValidateJWTToken(token, keys, settings)
+	assert.Error(t, err)
+	_, err = ValidateTwoFactorToken(token, keys, settings)
+	assert.Error(t, err)
+
+	mfaToken, err := GenerateTwoFactorToken(userID, keys, settings, time.Now().Add(5*time.Minute))
+	require.NoError(t, err)
+	_, err = ValidateReauthToken(mfaToken, keys, settings)
+	assert.Error(t, err)
+
+	expired, err := GenerateReauthToken(userID, keys, settings, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = ValidateReauthToken(expired, keys, settings)
+	assert.Error(t, err)
+}
@@ -1,14 +1,11 @@
 package security
 
 import (
-	"log"
-	"os"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -57,18 +54,18 @@ func TestIsValidateEmailFormat(t *testing.T) {
 }
 
 func TestValidateJWTToken(t *testing.T) {
-	if os.Getenv("CI") == "" {
-		err := godotenv.Load("../.env.test")
-		if err != nil {
-			log.Fatal("Error loading .env.test file")
-		}
+	settings := TokenSettings{
+		Issuer:   "booking-api",
+		Audience: "booking-frontend",
+		Leeway:   5 * time.Second,
 	}
-
-	// Retrieve values from .env
-	apiServiceName := os.Getenv("API_SERVICE_NAME")
-	frontendAppName := os.Getenv("FRONTEND_APP_NAME")
+	apiServiceName := settings.Issuer
+	frontendAppName := settings.Audience
 
 	secret := "mysecret"
+	keys, err := NewHMACKeyRing(LegacyHMACKeyID, secret)
+	assert.NoError(t, err)
+
 	expirationTime := time.Now().Add(1 * time.Hour)
 	userID := uuid.New()
 	claims := &Claims{
@@ -85,7 +82,7 @@ func TestValidateJWTToken(t *testing.T) {
 	tokenString, err := token.SignedString([]byte(secret))
 	assert.NoError(t, err)
 
-	result, err := ValidateJWTToken(tokenString, secret)
+	result, err := ValidateJWTToken(tokenString, keys, settings)
 	assert.NoError(t, err)
 	assert.Equal(t, claims.UserID, result.UserID)
 	assert.Equal(t, claims.Issuer, result.Issuer)
@@ -93,7 +90,7 @@ func TestValidateJWTToken(t *testing.T) {
 	assert.Equal(t, claims.ExpiresAt, result.ExpiresAt)
 	assert.Equal(t, claims.NotBefore, result.NotBefore)
 
-	_, err = ValidateJWTToken("invalid.token.here", secret)
+	_, err = ValidateJWTToken("invalid.token.here", keys, settings)
 	assert.Error(t, err)
 
 	expiredClaims := &Claims{
@@ -110,7 +107,12 @@ func TestValidateJWTToken(t *testing.T) {
 	expiredTokenString, err := expiredToken.SignedString([]byte(secret))
 	assert.NoError(t, err)
 
-	_, err = ValidateJWTToken(expiredTokenString, secret)
+	_, err = ValidateJWTToken(expiredTokenString, keys, settings)
+	assert.Error(t, err)
+
+	otherAudience := settings
+	otherAudience.Audience = "another-app"
+	_, err = ValidateJWTToken(tokenString, keys, otherAudience)
 	assert.Error(t, err)
 }
 
@@ -128,11 +130,29 @@ func TestGenerateRandomSHA256HASH(t *testing.T) {
 	assert.Len(t, hash, 64)
 }
 
+func TestHashToken(t *testing.T) {
+	token, err := GenerateRandomSHA256HASH()
+	assert.NoError(t, err)
+
+	hashed := HashToken(token)
+	assert.Len(t, hashed, 64)
+	assert.NotEqual(t, token, hashed)
+	assert.Equal(t, hashed, HashToken(token))
+}
+
 func TestGenerateJWTToken(t *testing.T) {
-	secret := "test-secret"
+	keys, err := NewHMACKeyRing(LegacyHMACKeyID, "test-secret")
+	assert.NoError(t, err)
+
+	settings := TokenSettings{Issuer: "booking-api", Audience: "booking-frontend"}
+
 	userID := uuid.New()
 	expiresAt := time.Now().Add(1 * time.Hour)
-	tokenString, err := GenerateJWTToken(userID, secret, expiresAt)
+	tokenString, err := GenerateJWTToken(userID, keys, settings, expiresAt)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenString)
+
+	claims, err := ValidateJWTToken(tokenString, keys, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
 }
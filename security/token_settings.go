@@ -0,0 +1,13 @@
+package security
+
+import "time"
+
+// TokenSettings is shared by token signing and validation so both sides
+// always agree on the issuer, audience and lifetimes.
+type TokenSettings struct {
+	Issuer          string
+	Audience        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Leeway          time.Duration
+}
@@ -0,0 +1,103 @@
+package security
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B, SHA-1 key, truncated to six digits.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := GenerateTOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := ValidateTOTPCode(rfc6238Secret, "081804", now, 0)
+	require.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+
+	// One step of clock drift either way is tolerated.
+	_, ok = ValidateTOTPCode(rfc6238Secret, "081804", now.Add(30*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTPCode(rfc6238Secret, "081804", now.Add(90*time.Second), 0)
+	assert.False(t, ok)
+
+	// A code can't be replayed once its step has been used.
+	_, ok = ValidateTOTPCode(rfc6238Secret, "081804", now, step)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTPCode(rfc6238Secret, "000000", now, 0)
+	assert.False(t, ok)
+	_, ok = ValidateTOTPCode("not base32!", "081804", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	uri, err := url.Parse(TOTPProvisioningURI("Booking", "staff@example.com", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Booking:staff@example.com", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "Booking", uri.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	assert.Equal(t, "abcde-fghij", NormalizeRecoveryCode(" ABCDE FGHIJ "))
+	assert.Equal(t, "abcde-fghij", NormalizeRecoveryCode("abcdefghij"))
+}
+
+func TestTwoFactorToken(t *testing.T) {
+	keys, err := NewHMACKeyRing(LegacyHMACKeyID, "test-secret")
+	require.NoError(t, err)
+	settings := TokenSettings{Issuer: "booking-api", Audience: "booking-frontend"}
+
+	userID := uuid.New()
+	token, err := GenerateTwoFactorToken(userID, keys, settings, time.Now().Add(5*time.Minute))
+	require.NoError(t, err)
+
+	claims, err := ValidateTwoFactorToken(token, keys, settings)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	// Passing the password step must not grant an access token.
+	_, err = ValidateJWTToken(token, keys, settings)
+	assert.Error(t, err)
+}
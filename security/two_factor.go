@@ -0,0 +1,52 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// twoFactorAudience marks the short-lived token handed out after the
+// password check. It proves the first factor only and can't be used as an
+// access token.
+func twoFactorAudience(settings TokenSettings) string {
+	return settings.Issuer + "/two-factor"
+}
+
+func GenerateTwoFactorToken(userID uuid.UUID, keys *KeyRing, settings TokenSettings, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    settings.Issuer,
+			Audience:  []string{twoFactorAudience(settings)},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	return keys.Sign(claims)
+}
+
+func ValidateTwoFactorToken(tokenString string, keys *KeyRing, settings TokenSettings) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc,
+		jwt.WithValidMethods(keys.Methods()),
+		jwt.WithIssuer(settings.Issuer),
+		jwt.WithAudience(twoFactorAudience(settings)),
+		jwt.WithLeeway(settings.Leeway),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse two-factor token: %w", err)
+	}
+	if !token.Valid || claims.UserID == uuid.Nil {
+		return nil, errors.New("invalid two-factor token")
+	}
+
+	return claims, nil
+}
@@ -3,11 +3,9 @@ package security
 import (
 	"errors"
 	"fmt"
-	"os"
+	"net/url"
 	"regexp"
-	"time"
-
-	"slices"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -28,15 +26,17 @@ func IsValidateEmailFormat(email string) bool {
 	return re.MatchString(email)
 }
 
-func ValidateJWTToken(tokenString string, secret string) (*Claims, error) {
+func ValidateJWTToken(tokenString string, keys *KeyRing, settings TokenSettings) (*Claims, error) {
 	claims := &Claims{}
 
-	apiServiceName := os.Getenv("API_SERVICE_NAME")
-	frontendAppName := os.Getenv("FRONTEND_APP_NAME")
-
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc,
+		jwt.WithValidMethods(keys.Methods()),
+		jwt.WithIssuer(settings.Issuer),
+		jwt.WithAudience(settings.Audience),
+		jwt.WithLeeway(settings.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse token: %w", err)
 	}
@@ -44,25 +44,15 @@ func ValidateJWTToken(tokenString string, secret string) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
-	if claims.Issuer != apiServiceName {
-		return nil, fmt.Errorf("invalid issuer: expected 'my-api-service', got '%s'", claims.Issuer)
-	}
-
-	if !contains(claims.Audience, frontendAppName) {
-		return nil, fmt.Errorf("invalid audience: expected 'my-frontend-app', got '%s'", claims.Audience)
-	}
-
-	if claims.ExpiresAt.Before(time.Now()) {
-		return nil, fmt.Errorf("token expired")
-	}
-
-	if claims.NotBefore.After(time.Now()) {
-		return nil, fmt.Errorf("token not valid yet")
-	}
-
 	return claims, nil
 }
 
-func contains(slice []string, item string) bool {
-	return slices.Contains(slice, item)
+// NormalizeOrigin reduces a URL to its lowercase scheme://host[:port] form,
+// or returns "" when it isn't an absolute http(s) URL.
+func NormalizeOrigin(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
 }
@@ -0,0 +1,27 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (id, created_at, token_hash, expires_at, user_id)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetPasswordResetByTokenHash :one
+SELECT * FROM password_resets
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+LIMIT 1;
+
+-- name: MarkPasswordResetUsed :execrows
+UPDATE password_resets
+SET used_at = $1
+WHERE id = $2 AND used_at IS NULL;
+
+-- name: InvalidatePasswordResetsByUserID :exec
+UPDATE password_resets
+SET used_at = $1
+WHERE user_id = $2 AND used_at IS NULL;
+
+-- name: GetPasswordResetsByUserID :many
+SELECT * FROM password_resets
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeletePasswordResetsByUserID :exec
+DELETE FROM password_resets
+WHERE user_id = $1;
\ No newline at end of file
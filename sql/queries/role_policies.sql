@@ -0,0 +1,14 @@
+-- name: GetRolePolicy :one
+SELECT * FROM role_policies
+WHERE role = $1
+LIMIT 1;
+
+-- name: GetRolePolicies :many
+SELECT * FROM role_policies
+ORDER BY role ASC;
+
+-- name: UpsertRolePolicy :exec
+INSERT INTO role_policies (role, updated_at, require_totp)
+VALUES ($1, $2, $3)
+ON CONFLICT (role) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, require_totp = EXCLUDED.require_totp;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (id, created_at, provider, subject, email, user_id)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2
+LIMIT 1;
+
+-- name: GetUserIdentitiesByUserID :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteUserIdentitiesByUserID :exec
+DELETE FROM user_identities
+WHERE user_id = $1;
\ No newline at end of file
@@ -35,3 +35,84 @@ WHERE id = $5;
 UPDATE users
 SET updated_at = $1, api_key = $2, api_key_expires_at = $3
 WHERE id = $4;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1
+LIMIT 1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = $1, password = $2
+WHERE id = $3;
+
+-- name: MarkUserEmailVerified :exec
+UPDATE users
+SET updated_at = $1, email_verified_at = $2
+WHERE id = $3 AND email = $4;
+
+-- name: ClaimEmailVerificationSlot :execrows
+UPDATE users
+SET email_verification_sent_at = sqlc.arg(sent_at)
+WHERE id = sqlc.arg(id)
+AND (email_verification_sent_at IS NULL OR email_verification_sent_at < sqlc.arg(sent_before));
+
+-- name: UpdateUserEmail :exec
+UPDATE users
+SET updated_at = $1, email = $2, email_verified_at = NULL, email_verification_sent_at = NULL
+WHERE id = $3;
+
+-- name: UpdateUserTokensInvalidBefore :exec
+UPDATE users
+SET updated_at = $1, tokens_invalid_before = $2
+WHERE id = $3;
+
+-- name: RecordFailedLogin :one
+UPDATE users
+SET failed_login_attempts = failed_login_attempts + 1
+WHERE id = $1
+RETURNING failed_login_attempts;
+
+-- name: LockUser :exec
+UPDATE users
+SET failed_login_attempts = 0, locked_until = $1
+WHERE id = $2;
+
+-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_login_attempts = 0, locked_until = NULL
+WHERE id = $1;
+
+-- name: GetLockedUsers :many
+SELECT id, username, email, failed_login_attempts, locked_until FROM users
+WHERE locked_until > $1
+ORDER BY locked_until DESC;
+
+-- name: SetUserTotpSecret :exec
+UPDATE users
+SET updated_at = $1, totp_secret = $2, totp_enabled_at = NULL, totp_last_counter = 0
+WHERE id = $3;
+
+-- name: EnableUserTotp :exec
+UPDATE users
+SET updated_at = $1, totp_enabled_at = $2, totp_last_counter = $3
+WHERE id = $4;
+
+-- name: DisableUserTotp :exec
+UPDATE users
+SET updated_at = $1, totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0
+WHERE id = $2;
+
+-- name: UpdateUserTotpCounter :execrows
+UPDATE users
+SET totp_last_counter = $1
+WHERE id = $2 AND totp_last_counter < $1;
+
+-- name: AnonymizeUser :exec
+UPDATE users
+SET updated_at = $1, deleted_at = $2, full_name = $3, email = $4, phone = NULL, username = $5,
+    password = $6, api_key = $7, api_key_expires_at = $8,
+    email_verified_at = NULL, email_verification_sent_at = NULL, tokens_invalid_before = $9,
+    role = 'guest', failed_login_attempts = 0, locked_until = NULL,
+    totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0
+WHERE id = $10;
\ No newline at end of file
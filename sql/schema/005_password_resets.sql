@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE
+    password_resets (
+        id TEXT PRIMARY KEY,
+        created_at TIMESTAMP NOT NULL,
+        token_hash TEXT UNIQUE NOT NULL,
+        expires_at TIMESTAMP NOT NULL,
+        used_at TIMESTAMP,
+        user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+    );
+
+-- +goose Down
+DROP TABLE IF EXISTS password_resets;
\ No newline at end of file
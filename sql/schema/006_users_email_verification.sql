@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN email_verification_sent_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_verification_sent_at,
+    DROP COLUMN IF EXISTS email_verified_at;
\ No newline at end of file
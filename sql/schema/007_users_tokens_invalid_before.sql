@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN tokens_invalid_before TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS tokens_invalid_before;
\ No newline at end of file
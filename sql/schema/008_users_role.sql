@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'guest';
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS role;
\ No newline at end of file
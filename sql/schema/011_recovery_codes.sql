@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE
+    recovery_codes (
+        id TEXT PRIMARY KEY,
+        created_at TIMESTAMP NOT NULL,
+        code_hash TEXT NOT NULL,
+        used_at TIMESTAMP,
+        user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        UNIQUE (user_id, code_hash)
+    );
+
+-- +goose Down
+DROP TABLE IF EXISTS recovery_codes;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE
+    role_policies (
+        role TEXT PRIMARY KEY,
+        updated_at TIMESTAMP NOT NULL,
+        require_totp BOOLEAN NOT NULL DEFAULT FALSE
+    );
+
+-- +goose Down
+DROP TABLE IF EXISTS role_policies;
\ No newline at end of file
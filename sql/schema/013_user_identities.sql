@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE
+    user_identities (
+        id TEXT PRIMARY KEY,
+        created_at TIMESTAMP NOT NULL,
+        provider TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        email TEXT,
+        user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        UNIQUE (provider, subject)
+    );
+
+-- +goose Down
+DROP TABLE IF EXISTS user_identities;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN deleted_at TIMESTAMP;
+
+-- Deleting a user must never take their booking history with it; accounts
+-- are anonymised instead.
+ALTER TABLE bookings
+DROP CONSTRAINT bookings_user_id_fkey,
+ADD CONSTRAINT bookings_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT;
+
+-- +goose Down
+ALTER TABLE bookings
+DROP CONSTRAINT bookings_user_id_fkey,
+ADD CONSTRAINT bookings_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE users
+DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1,8 @@
+// Package schema embeds the goose migrations so the binary can check and
+// apply them without the sql directory next to it.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS